@@ -0,0 +1,258 @@
+// Package builder describes how llama.cpp is configured and built: the
+// GGML compute backends, their CMake options and their prerequisites.
+package builder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Requirement is one prerequisite of a backend. It is met when any of its
+// commands is on PATH or any of its files exists.
+type Requirement struct {
+	Name     string
+	Commands []string
+
+	// Files are absolute paths, looked up under Env.Root
+	Files []string
+
+	// Packages name the distribution packages providing the requirement,
+	// by distribution family (see Distro)
+	Packages map[string][]string
+}
+
+// Backend is a GGML compute backend llama.cpp can be built with
+type Backend struct {
+	Name        string
+	Description string
+
+	// Option is the CMake option enabling the backend; empty for the CPU
+	Option string
+
+	// Extra are further CMake options the backend needs
+	Extra []string
+
+	Requirements []Requirement
+
+	// Auto marks backends chosen by autodetection when their prerequisites
+	// are installed
+	Auto bool
+
+	// Vendor is the GPU vendor the backend serves, as named by package hw;
+	// empty for any vendor
+	Vendor string
+
+	// Hint explains how to install the prerequisites
+	Hint string
+}
+
+// Backends are the supported backends, in the order autodetection tries them
+var Backends = []*Backend{
+	{
+		Name:        "cuda",
+		Vendor:      "nvidia",
+		Description: "NVIDIA GPUs with the CUDA toolkit",
+		Option:      "GGML_CUDA",
+		Extra:       []string{"-DGGML_CUDA_FA_ALL_QUANTS=ON"},
+		Requirements: []Requirement{
+			{Name: "CUDA compiler (nvcc)", Commands: []string{"nvcc"}, Files: []string{"/usr/local/cuda/bin/nvcc", "/opt/cuda/bin/nvcc"}},
+		},
+		Auto: true,
+		Hint: "install the CUDA toolkit and add its bin directory (e.g. /usr/local/cuda/bin) to PATH",
+	},
+	{
+		Name:        "hip",
+		Vendor:      "amd",
+		Description: "AMD GPUs with ROCm",
+		Option:      "GGML_HIP",
+		Requirements: []Requirement{
+			{Name: "ROCm HIP compiler (hipconfig)", Commands: []string{"hipconfig"}, Files: []string{"/opt/rocm/bin/hipconfig"}},
+		},
+		Auto: true,
+		Hint: "install ROCm with the HIP SDK; pass -DAMDGPU_TARGETS=<gfx arch> if CMake can't detect your GPU",
+	},
+	{
+		Name:        "sycl",
+		Vendor:      "intel",
+		Description: "Intel GPUs with oneAPI",
+		Option:      "GGML_SYCL",
+		Extra:       []string{"-DCMAKE_C_COMPILER=icx", "-DCMAKE_CXX_COMPILER=icpx"},
+		Requirements: []Requirement{
+			{Name: "oneAPI DPC++ compiler (icpx)", Commands: []string{"icpx"}},
+		},
+		Auto: true,
+		Hint: "install the Intel oneAPI Base Toolkit and run 'source /opt/intel/oneapi/setvars.sh' before building",
+	},
+	{
+		Name:        "vulkan",
+		Description: "any GPU with a Vulkan driver",
+		Option:      "GGML_VULKAN",
+		Requirements: []Requirement{
+			{Name: "Vulkan headers", Files: []string{"/usr/include/vulkan/vulkan.h", "/usr/local/include/vulkan/vulkan.h"}, Packages: map[string][]string{
+				"debian": {"libvulkan-dev"},
+				"fedora": {"vulkan-headers", "vulkan-loader-devel"},
+				"arch":   {"vulkan-headers", "vulkan-icd-loader"},
+				"suse":   {"vulkan-devel"},
+				"alpine": {"vulkan-headers", "vulkan-loader-dev"},
+			}},
+			{Name: "GLSL compiler (glslc)", Commands: []string{"glslc"}, Packages: map[string][]string{
+				"debian": {"glslc"},
+				"fedora": {"glslc"},
+				"arch":   {"shaderc"},
+				"suse":   {"shaderc"},
+				"alpine": {"shaderc"},
+			}},
+		},
+		Auto: true,
+		Hint: "install the Vulkan SDK, or your distribution's Vulkan headers and glslc (shaderc) packages",
+	},
+	{
+		Name:        "blas",
+		Description: "CPU with OpenBLAS for faster prompt processing",
+		Option:      "GGML_BLAS",
+		Extra:       []string{"-DGGML_BLAS_VENDOR=OpenBLAS"},
+		Requirements: []Requirement{
+			{Name: "OpenBLAS headers", Files: []string{
+				"/usr/include/openblas/cblas.h",
+				"/usr/include/x86_64-linux-gnu/openblas-pthread/cblas.h",
+				"/usr/include/aarch64-linux-gnu/openblas-pthread/cblas.h",
+				"/usr/include/cblas.h",
+			}, Packages: map[string][]string{
+				"debian": {"libopenblas-dev"},
+				"fedora": {"openblas-devel"},
+				"arch":   {"openblas"},
+				"suse":   {"openblas-devel"},
+				"alpine": {"openblas-dev"},
+			}},
+		},
+		Hint: "install your distribution's OpenBLAS development package",
+	},
+	{
+		Name:        "cpu",
+		Description: "CPU only",
+	},
+}
+
+// Env is what detection looks at. Tests can use a fake PATH and a fake
+// filesystem root.
+type Env struct {
+	// LookPath finds a command on PATH
+	LookPath func(name string) (string, error)
+
+	// Root is prepended to every file checked
+	Root string
+}
+
+// SystemEnv returns the environment of the running system
+func SystemEnv() Env {
+	return Env{LookPath: exec.LookPath, Root: "/"}
+}
+
+// PathEnv returns an environment searching the directories of path, as in
+// $PATH, for commands and root for files
+func PathEnv(path, root string) Env {
+	return Env{
+		LookPath: func(name string) (string, error) {
+			for _, dir := range filepath.SplitList(path) {
+				candidate := filepath.Join(dir, name)
+				if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+					return candidate, nil
+				}
+			}
+			return "", exec.ErrNotFound
+		},
+		Root: root,
+	}
+}
+
+// Lookup returns the backend with the given name
+func Lookup(name string) (*Backend, error) {
+	var names []string
+	for _, backend := range Backends {
+		if backend.Name == name {
+			return backend, nil
+		}
+		names = append(names, backend.Name)
+	}
+	return nil, fmt.Errorf("unknown backend %s (choose from %s)", name, strings.Join(names, ", "))
+}
+
+// Met reports whether the requirement is satisfied in env
+func (r Requirement) Met(env Env) bool {
+	for _, command := range r.Commands {
+		if _, err := env.LookPath(command); err == nil {
+			return true
+		}
+	}
+	for _, file := range r.Files {
+		if _, err := os.Stat(filepath.Join(env.Root, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the names of the backend's unmet requirements
+func (b *Backend) Missing(env Env) []string {
+	var missing []string
+	for _, requirement := range b.Requirements {
+		if !requirement.Met(env) {
+			missing = append(missing, requirement.Name)
+		}
+	}
+	return missing
+}
+
+// Detect returns the first backend tried by autodetection whose
+// prerequisites are installed, falling back to the CPU backend. When the
+// GPU vendors present are known, only backends serving them are tried;
+// without any, for example in a container without /sys, toolchains alone
+// decide.
+func Detect(env Env, vendors []string) *Backend {
+	for _, backend := range Backends {
+		if backend.Auto && backend.Serves(vendors) && len(backend.Missing(env)) == 0 {
+			return backend
+		}
+	}
+	return CPU()
+}
+
+// Serves reports whether the backend can use a GPU of one of vendors. Any
+// backend qualifies when vendors is empty.
+func (b *Backend) Serves(vendors []string) bool {
+	if len(vendors) == 0 || b.Vendor == "" {
+		return true
+	}
+	for _, vendor := range vendors {
+		if vendor == b.Vendor {
+			return true
+		}
+	}
+	return false
+}
+
+// CPU returns the CPU-only backend
+func CPU() *Backend {
+	return Backends[len(Backends)-1]
+}
+
+// CMakeOptions returns the -D options selecting the backend. Every other
+// backend is switched off explicitly, since CMake keeps options from an
+// earlier configure of the same build directory.
+func (b *Backend) CMakeOptions() []string {
+	var options []string
+	for _, backend := range Backends {
+		if backend.Option == "" {
+			continue
+		}
+		value := "OFF"
+		if backend == b {
+			value = "ON"
+		}
+		options = append(options, "-D"+backend.Option+"="+value)
+	}
+	return append(options, b.Extra...)
+}
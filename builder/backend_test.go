@@ -0,0 +1,130 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeEnv returns an environment whose PATH holds only the given commands,
+// as empty executables, and whose root holds only the given files
+func fakeEnv(t *testing.T, commands, files []string) Env {
+	t.Helper()
+	bin := t.TempDir()
+	for _, command := range commands {
+		if err := os.WriteFile(filepath.Join(bin, command), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file that isn't executable, earlier on PATH, is not a command
+	noexec := t.TempDir()
+	if err := os.WriteFile(filepath.Join(noexec, "glslc"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return PathEnv(strings.Join([]string{noexec, filepath.Join(bin, "missing"), bin}, string(filepath.ListSeparator)), root)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		files    []string
+		vendors  []string
+		want     string
+	}{
+		{"nothing installed", nil, nil, nil, "cpu"},
+		{"nvcc on PATH", []string{"nvcc"}, nil, nil, "cuda"},
+		{"nvcc in /usr/local/cuda", nil, []string{"/usr/local/cuda/bin/nvcc"}, nil, "cuda"},
+		{"nvcc without an NVIDIA GPU", []string{"nvcc"}, nil, []string{"amd"}, "cpu"},
+		{"CUDA and ROCm on an AMD machine", []string{"nvcc", "hipconfig"}, nil, []string{"amd"}, "hip"},
+		{"ROCm in /opt/rocm", nil, []string{"/opt/rocm/bin/hipconfig"}, []string{"amd"}, "hip"},
+		{"oneAPI", []string{"icpx"}, nil, []string{"intel"}, "sycl"},
+		{"Vulkan", []string{"glslc"}, []string{"/usr/include/vulkan/vulkan.h"}, []string{"intel"}, "vulkan"},
+		{"Vulkan headers without glslc", nil, []string{"/usr/include/vulkan/vulkan.h"}, nil, "cpu"},
+		{"Vulkan on an NVIDIA machine without CUDA", []string{"glslc"}, []string{"/usr/local/include/vulkan/vulkan.h"}, []string{"nvidia"}, "vulkan"},
+		{"OpenBLAS is never chosen", nil, []string{"/usr/include/cblas.h"}, nil, "cpu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := fakeEnv(t, tt.commands, tt.files)
+			if got := Detect(env, tt.vendors); got.Name != tt.want {
+				t.Errorf("Detect = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissing(t *testing.T) {
+	tests := []struct {
+		backend  string
+		commands []string
+		files    []string
+		want     []string
+	}{
+		{"cpu", nil, nil, nil},
+		{"cuda", nil, nil, []string{"CUDA compiler (nvcc)"}},
+		{"cuda", nil, []string{"/opt/cuda/bin/nvcc"}, nil},
+		{"vulkan", nil, nil, []string{"Vulkan headers", "GLSL compiler (glslc)"}},
+		{"vulkan", []string{"glslc"}, nil, []string{"Vulkan headers"}},
+		{"blas", nil, []string{"/usr/include/openblas/cblas.h"}, nil},
+	}
+
+	for _, tt := range tests {
+		backend, err := Lookup(tt.backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := backend.Missing(fakeEnv(t, tt.commands, tt.files))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s with %v and %v: Missing = %q, want %q", tt.backend, tt.commands, tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, backend := range Backends {
+		got, err := Lookup(backend.Name)
+		if err != nil || got != backend {
+			t.Errorf("Lookup(%q) = %v, %v", backend.Name, got, err)
+		}
+	}
+	if _, err := Lookup("metal"); err == nil {
+		t.Error("Lookup of an unknown backend succeeded")
+	}
+	if CPU().Name != "cpu" {
+		t.Errorf("CPU() = %s", CPU().Name)
+	}
+}
+
+func TestCMakeOptions(t *testing.T) {
+	backend, err := Lookup("hip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-DGGML_CUDA=OFF", "-DGGML_HIP=ON", "-DGGML_SYCL=OFF", "-DGGML_VULKAN=OFF", "-DGGML_BLAS=OFF"}
+	if got := backend.CMakeOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CMakeOptions = %q, want %q", got, want)
+	}
+
+	got := CPU().CMakeOptions()
+	for _, option := range got {
+		if !strings.HasSuffix(option, "=OFF") {
+			t.Errorf("CPU backend enables %s", option)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// logFile keeps the output of the last configure and build
+const logFile = "llamarunner-build.log"
+
+// LogPath returns the build log kept in a build directory
+func LogPath(buildDir string) string {
+	return filepath.Join(buildDir, logFile)
+}
+
+// Diagnosis explains a known cause of a failed build
+type Diagnosis struct {
+	Problem string
+	Fix     string
+}
+
+// failurePattern recognizes a known cause of failure in the build output
+type failurePattern struct {
+	pattern *regexp.Regexp
+	Diagnosis
+}
+
+// failurePatterns are tried against every line of the build log
+var failurePatterns = []failurePattern{
+	{
+		pattern: regexp.MustCompile(`(?i)(No CMAKE_CUDA_COMPILER could be found|Could not find nvcc|Failed to find nvcc|CUDA Toolkit not found|Could NOT find CUDAToolkit)`),
+		Diagnosis: Diagnosis{
+			Problem: "The CUDA toolkit was not found.",
+			Fix:     "Install the CUDA toolkit and add its bin directory (e.g. /usr/local/cuda/bin) to PATH, or build for another backend with --backend.",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)(Unsupported gpu architecture|unsupported CUDA architecture|invalid (target|offload arch)|unsupported (HIP|AMDGPU) (target|architecture)|Cannot find ROCm device library)`),
+		Diagnosis: Diagnosis{
+			Problem: "The compiler does not support the GPU architecture it was asked to build for.",
+			Fix:     "Name the architectures your toolkit supports, e.g. -D CMAKE_CUDA_ARCHITECTURES=86 for CUDA or -D AMDGPU_TARGETS=gfx1100 for ROCm, or update the toolkit.",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)(Could NOT find CURL|curl/curl\.h: No such file or directory|'curl/curl\.h' file not found)`),
+		Diagnosis: Diagnosis{
+			Problem: "The libcurl development headers are missing; llama.cpp needs them to download models.",
+			Fix:     "Install your distribution's libcurl development package (e.g. libcurl4-openssl-dev or libcurl-devel), or build without download support with -D LLAMA_CURL=OFF.",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)(unrecognized command[- ]line option .-std=(c|gnu)\+\+(17|20)|does not support C\+\+(17|20)|compiler version .* is (too old|not supported)|requires (at least )?(GCC|Clang) \d+|(GCC|compiler) version must be at least|invalid value '(c|gnu)\+\+(17|20)' in '-std=)`),
+		Diagnosis: Diagnosis{
+			Problem: "The compiler is too old for llama.cpp, which needs C++17.",
+			Fix:     "Install GCC 9 or Clang 10 or newer and select it with --cc and --cxx (e.g. --cc gcc-13 --cxx g++-13).",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)(internal compiler error: Killed|fatal error: Killed signal terminated program|virtual memory exhausted|out of memory allocating|cannot allocate memory|c\+\+: fatal error: Killed)`),
+		Diagnosis: Diagnosis{
+			Problem: "The compiler ran out of memory.",
+			Fix:     "Build with fewer parallel jobs, e.g. --jobs 2, or add swap space.",
+		},
+	},
+}
+
+// Diagnose returns the known causes of failure found in build output, in
+// the order of failurePatterns, each at most once
+func Diagnose(r io.Reader) ([]Diagnosis, error) {
+	found := make([]bool, len(failurePatterns))
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for i, failure := range failurePatterns {
+			if !found[i] && failure.pattern.MatchString(line) {
+				found[i] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var diagnoses []Diagnosis
+	for i, failure := range failurePatterns {
+		if found[i] {
+			diagnoses = append(diagnoses, failure.Diagnosis)
+		}
+	}
+	return diagnoses, nil
+}
+
+// DiagnoseLog returns the known causes of failure found in the log at path
+func DiagnoseLog(path string) ([]Diagnosis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Diagnose(f)
+}
@@ -0,0 +1,37 @@
+package builder
+
+// compilerLaunchers are the compiler caches used when installed, in order
+// of preference
+var compilerLaunchers = []string{"ccache", "sccache"}
+
+// CompilerLauncher returns the first compiler cache on PATH, or ""
+func CompilerLauncher(env Env) string {
+	for _, launcher := range compilerLaunchers {
+		if _, err := env.LookPath(launcher); err == nil {
+			return launcher
+		}
+	}
+	return ""
+}
+
+// LauncherOptions returns the CMake options sending every compile of the
+// backend through launcher. llama.cpp's own ccache detection is turned off
+// so the launcher isn't applied twice.
+func (b *Backend) LauncherOptions(launcher string) []string {
+	if launcher == "" {
+		return nil
+	}
+
+	options := []string{
+		"-DGGML_CCACHE=OFF",
+		"-DCMAKE_C_COMPILER_LAUNCHER=" + launcher,
+		"-DCMAKE_CXX_COMPILER_LAUNCHER=" + launcher,
+	}
+	switch b.Name {
+	case "cuda":
+		options = append(options, "-DCMAKE_CUDA_COMPILER_LAUNCHER="+launcher)
+	case "hip":
+		options = append(options, "-DCMAKE_HIP_COMPILER_LAUNCHER="+launcher)
+	}
+	return options
+}
@@ -0,0 +1,199 @@
+package builder
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github/llamarunner/utils"
+)
+
+// manifestFile sits next to build/bin and describes what was built
+const manifestFile = "llamarunner-manifest.json"
+
+// Binary is one program produced by a build
+type Binary struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	// Commit is set for a binary built later, on demand, from another
+	// commit than the rest of the build
+	Commit string `json:"commit,omitempty"`
+}
+
+// Manifest records the provenance of a build, so that a result can be tied
+// to the exact source, configuration and machine that produced it
+type Manifest struct {
+	Commit string `json:"commit"`
+	Dirty  bool   `json:"dirty"`
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+
+	Backend      string   `json:"backend"`
+	Profile      string   `json:"profile,omitempty"`
+	CMakeOptions []string `json:"cmake_options"`
+	Compiler     string   `json:"compiler"`
+
+	Finished time.Time `json:"finished"`
+	Duration float64   `json:"duration_seconds"`
+	CPUFlags []string  `json:"cpu_flags"`
+
+	Binaries []Binary `json:"binaries"`
+}
+
+// ManifestPath returns the manifest kept in a build directory
+func ManifestPath(buildDir string) string {
+	return filepath.Join(buildDir, manifestFile)
+}
+
+// SaveManifest writes the manifest into buildDir
+func SaveManifest(buildDir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ManifestPath(buildDir), append(data, '\n'), 0644)
+}
+
+// LoadManifest reads the manifest of buildDir, returning nil if it has none
+func LoadManifest(buildDir string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(buildDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Binary returns the recorded binary with the given name, or nil
+func (m *Manifest) Binary(name string) *Binary {
+	for i := range m.Binaries {
+		if m.Binaries[i].Name == name {
+			return &m.Binaries[i]
+		}
+	}
+	return nil
+}
+
+// SetBinary records binary, replacing an entry of the same name
+func (m *Manifest) SetBinary(binary Binary) {
+	if recorded := m.Binary(binary.Name); recorded != nil {
+		*recorded = binary
+		return
+	}
+	m.Binaries = append(m.Binaries, binary)
+	sort.Slice(m.Binaries, func(i, j int) bool { return m.Binaries[i].Name < m.Binaries[j].Name })
+}
+
+// ReadGit fills in the commit, dirty state, branch and tag of the checkout
+// at srcDir. Fields git can't tell are left empty; the commit is "unknown"
+// outside a git checkout.
+func (m *Manifest) ReadGit(srcDir string) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = srcDir
+		output, err := cmd.Output()
+		return strings.TrimSpace(string(output)), err
+	}
+
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		m.Commit = "unknown"
+		return
+	}
+	m.Commit = commit
+
+	// Build output is ignored by llama.cpp's .gitignore, so only source
+	// changes count
+	status, err := git("status", "--porcelain", "--untracked-files=no")
+	m.Dirty = err == nil && status != ""
+
+	if branch, err := git("symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		m.Branch = branch
+	}
+	if tag, err := git("describe", "--tags", "--exact-match"); err == nil {
+		m.Tag = tag
+	}
+}
+
+// CompilerVersion returns the first line of "--version" of the C++ compiler
+// CMake chose for buildDir, or "unknown"
+func CompilerVersion(buildDir string) string {
+	f, err := os.Open(filepath.Join(buildDir, "CMakeCache.txt"))
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+
+	compiler := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "CMAKE_CXX_COMPILER:") {
+			_, compiler, _ = strings.Cut(line, "=")
+			break
+		}
+	}
+	if compiler == "" {
+		return "unknown"
+	}
+
+	output, err := exec.Command(compiler, "--version").Output()
+	if err != nil {
+		return compiler
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return version
+}
+
+// ScanBinaries hashes the executables in binDir
+func ScanBinaries(binDir string) ([]Binary, error) {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var binaries []Binary
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		binary, err := HashBinary(filepath.Join(binDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		binaries = append(binaries, binary)
+	}
+
+	sort.Slice(binaries, func(i, j int) bool { return binaries[i].Name < binaries[j].Name })
+	return binaries, nil
+}
+
+// HashBinary describes the executable at path
+func HashBinary(path string) (Binary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Binary{}, err
+	}
+	hash, err := utils.HashFile(path)
+	if err != nil {
+		return Binary{}, err
+	}
+	return Binary{Name: filepath.Base(path), Size: info.Size(), SHA256: hash}, nil
+}
@@ -0,0 +1,340 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github/llamarunner/utils"
+)
+
+// DefaultMinCMakeVersion is required when the checkout doesn't say
+const DefaultMinCMakeVersion = "3.14"
+
+// Free space a build needs. GPU backends compile kernels for many
+// architectures and quantization types, which takes several times more.
+const (
+	minFreeSpace    = 2 << 30
+	minFreeSpaceGPU = 8 << 30
+)
+
+// Distro is the Linux distribution, which decides package names and how to
+// install them
+type Distro struct {
+	Name string
+
+	// Family is "debian", "fedora", "arch", "suse" or "alpine", or empty
+	// when unknown
+	Family string
+}
+
+// distroFamilies maps os-release IDs to the family whose packages they use
+var distroFamilies = map[string]string{
+	"debian": "debian", "ubuntu": "debian", "linuxmint": "debian", "pop": "debian",
+	"fedora": "fedora", "rhel": "fedora", "centos": "fedora", "rocky": "fedora", "almalinux": "fedora",
+	"arch": "arch", "manjaro": "arch", "endeavouros": "arch",
+	"opensuse": "suse", "suse": "suse", "sles": "suse",
+	"alpine": "alpine",
+}
+
+// installCommands are the commands installing packages in each family
+var installCommands = map[string]string{
+	"debian": "sudo apt install",
+	"fedora": "sudo dnf install",
+	"arch":   "sudo pacman -S --needed",
+	"suse":   "sudo zypper install",
+	"alpine": "sudo apk add",
+}
+
+// DetectDistro reads /etc/os-release under root
+func DetectDistro(root string) Distro {
+	f, err := os.Open(filepath.Join(root, "/etc/os-release"))
+	if err != nil {
+		return Distro{Name: "unknown Linux distribution"}
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"'`)
+		}
+	}
+
+	distro := Distro{Name: values["PRETTY_NAME"]}
+	if distro.Name == "" {
+		distro.Name = values["ID"]
+	}
+
+	// ID_LIKE names the distributions this one derives from
+	for _, id := range strings.Fields(values["ID"] + " " + values["ID_LIKE"]) {
+		id = strings.SplitN(id, "-", 2)[0]
+		if family, ok := distroFamilies[id]; ok {
+			distro.Family = family
+			break
+		}
+	}
+	return distro
+}
+
+// InstallCommand returns the command installing packages, or "" when the
+// distribution family is unknown
+func (d Distro) InstallCommand(packages []string) string {
+	command, ok := installCommands[d.Family]
+	if !ok || len(packages) == 0 {
+		return ""
+	}
+	return command + " " + strings.Join(packages, " ")
+}
+
+// Check is one item of the preflight checklist
+type Check struct {
+	Name string
+	OK   bool
+
+	// Optional items are reported but don't stop a build
+	Optional bool
+
+	// Detail is what was found, such as a version
+	Detail string
+
+	Packages map[string][]string
+
+	// Hint explains how to install items no package provides
+	Hint string
+}
+
+// PreflightOptions describe the build being checked
+type PreflightOptions struct {
+	Env Env
+
+	// Dir is the llama.cpp checkout, which may not exist yet
+	Dir string
+
+	// Backend is the backend to build for; when nil, the toolchains of
+	// backends serving Vendors are checked as optional items
+	Backend *Backend
+	Vendors []string
+
+	// CCompiler and CXXCompiler replace the default compilers
+	CCompiler   string
+	CXXCompiler string
+
+	// Curl checks for the libcurl headers -DLLAMA_CURL=ON needs
+	Curl bool
+}
+
+// everywhere gives a package the same name in every family
+func everywhere(names ...string) map[string][]string {
+	packages := map[string][]string{}
+	for family := range installCommands {
+		packages[family] = names
+	}
+	return packages
+}
+
+// compilerPackages provide a C and C++ compiler in each family
+var compilerPackages = map[string][]string{
+	"debian": {"build-essential"},
+	"fedora": {"gcc", "gcc-c++"},
+	"arch":   {"base-devel"},
+	"suse":   {"gcc", "gcc-c++"},
+	"alpine": {"build-base"},
+}
+
+// curlRequirement is met by the libcurl development headers
+var curlRequirement = Requirement{
+	Name:     "libcurl development headers",
+	Commands: []string{"curl-config"},
+	Files: []string{
+		"/usr/include/curl/curl.h",
+		"/usr/include/x86_64-linux-gnu/curl/curl.h",
+		"/usr/include/aarch64-linux-gnu/curl/curl.h",
+		"/usr/local/include/curl/curl.h",
+	},
+	Packages: map[string][]string{
+		"debian": {"libcurl4-openssl-dev"},
+		"fedora": {"libcurl-devel"},
+		"arch":   {"curl"},
+		"suse":   {"libcurl-devel"},
+		"alpine": {"curl-dev"},
+	},
+}
+
+// Preflight checks everything a llama.cpp build needs, in the order they
+// are used
+func Preflight(opts PreflightOptions) []Check {
+	var checks []Check
+	require := func(requirement Requirement, optional bool, hint string) {
+		checks = append(checks, Check{
+			Name:     requirement.Name,
+			OK:       requirement.Met(opts.Env),
+			Optional: optional,
+			Packages: requirement.Packages,
+			Hint:     hint,
+		})
+	}
+
+	require(Requirement{Name: "git", Commands: []string{"git"}, Packages: everywhere("git")}, false, "")
+	checks = append(checks, checkCMake(opts.Env, MinCMakeVersion(opts.Dir)))
+
+	cc := []string{"cc", "gcc", "clang"}
+	if opts.CCompiler != "" {
+		cc = []string{opts.CCompiler}
+	}
+	cxx := []string{"c++", "g++", "clang++"}
+	if opts.CXXCompiler != "" {
+		cxx = []string{opts.CXXCompiler}
+	}
+	require(Requirement{Name: "C compiler (" + strings.Join(cc, ", ") + ")", Commands: cc, Packages: compilerPackages}, false, "")
+	require(Requirement{Name: "C++ compiler (" + strings.Join(cxx, ", ") + ")", Commands: cxx, Packages: compilerPackages}, false, "")
+	require(Requirement{Name: "make or ninja", Commands: []string{"make", "ninja"}, Packages: everywhere("make")}, false, "")
+
+	if opts.Curl {
+		require(curlRequirement, false, "")
+	}
+
+	if opts.Backend != nil {
+		for _, requirement := range opts.Backend.Requirements {
+			requirement.Name += " for the " + opts.Backend.Name + " backend"
+			require(requirement, false, opts.Backend.Hint)
+		}
+	} else if len(opts.Vendors) > 0 {
+		for _, backend := range Backends {
+			if !backend.Auto || backend.Vendor == "" || !backend.Serves(opts.Vendors) {
+				continue
+			}
+			for _, requirement := range backend.Requirements {
+				requirement.Name += " for " + backend.Vendor + " GPUs (" + backend.Name + " backend)"
+				require(requirement, true, backend.Hint)
+			}
+		}
+	}
+
+	needed := int64(minFreeSpace)
+	if opts.Backend != nil && opts.Backend.Vendor != "" {
+		needed = minFreeSpaceGPU
+	}
+	checks = append(checks, checkFreeSpace(opts.Dir, needed))
+
+	return checks
+}
+
+// Failed reports whether a required check failed
+func Failed(checks []Check) bool {
+	for _, check := range checks {
+		if !check.OK && !check.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// cmakeMinimumPattern finds the version a CMakeLists.txt requires
+var cmakeMinimumPattern = regexp.MustCompile(`(?i)cmake_minimum_required\s*\(\s*VERSION\s+([0-9.]+)`)
+
+// MinCMakeVersion returns the CMake version the checkout at dir requires,
+// or DefaultMinCMakeVersion when there is no checkout yet
+func MinCMakeVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "CMakeLists.txt"))
+	if err != nil {
+		return DefaultMinCMakeVersion
+	}
+	match := cmakeMinimumPattern.FindSubmatch(data)
+	if match == nil {
+		return DefaultMinCMakeVersion
+	}
+	return string(match[1])
+}
+
+// cmakeVersionPattern finds the version in "cmake --version"
+var cmakeVersionPattern = regexp.MustCompile(`cmake version ([0-9]+(\.[0-9]+)*)`)
+
+// checkCMake checks that cmake is installed and at least version minimum
+func checkCMake(env Env, minimum string) Check {
+	check := Check{Name: "cmake " + minimum + " or newer", Packages: everywhere("cmake")}
+
+	path, err := env.LookPath("cmake")
+	if err != nil {
+		return check
+	}
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s --version failed", path)
+		return check
+	}
+	match := cmakeVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		check.Detail = "unknown version"
+		return check
+	}
+
+	check.Detail = match[1]
+	check.OK = compareVersions(match[1], minimum) >= 0
+	if !check.OK {
+		check.Hint = "your distribution's cmake is too old; install a newer one with 'pip install cmake' or from cmake.org"
+	}
+	return check
+}
+
+// compareVersions compares dotted version numbers, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkFreeSpace checks the free space on the file system dir is, or will
+// be, on
+func checkFreeSpace(dir string, needed int64) Check {
+	check := Check{Name: fmt.Sprintf("%d GiB of free disk space", needed>>30)}
+
+	// Look at the nearest existing parent when dir doesn't exist yet
+	for dir != "" && dir != string(filepath.Separator) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if dir == "" {
+		dir = "."
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		// Don't stop a build over a file system we can't inspect
+		check.OK = true
+		check.Detail = "unknown"
+		return check
+	}
+
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	check.OK = free >= needed
+	check.Detail = fmt.Sprintf("%s free in %s", utils.FormatBytes(free), dir)
+	if !check.OK {
+		check.Hint = "free some space, e.g. with 'llamarunner du --prune', or build elsewhere"
+	}
+	return check
+}
@@ -0,0 +1,191 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// DefaultBuildType is used when a profile doesn't set one
+const DefaultBuildType = "Release"
+
+// profileNamePattern keeps profile names usable as file names
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile is a named, reusable set of build settings. Empty fields fall
+// back to the defaults: detected backend, default targets, Release, all
+// cores and the system compiler.
+type Profile struct {
+	Name string `toml:"-"`
+
+	Backend      string   `toml:"backend,omitempty"`
+	Targets      []string `toml:"targets,omitempty"`
+	CMakeOptions []string `toml:"cmake_options,omitempty"`
+	BuildType    string   `toml:"build_type,omitempty"`
+	Jobs         int      `toml:"jobs,omitempty"`
+	CCompiler    string   `toml:"c_compiler,omitempty"`
+	CXXCompiler  string   `toml:"cxx_compiler,omitempty"`
+}
+
+// ProfilePath returns the file of the named profile in dir, rejecting names
+// that aren't plain file names
+func ProfilePath(dir, name string) (string, error) {
+	if !profileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q: use letters, digits and . _ -", name)
+	}
+	return filepath.Join(dir, name+".toml"), nil
+}
+
+// LoadProfile reads the named profile from dir
+func LoadProfile(dir, name string) (*Profile, error) {
+	path, err := ProfilePath(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("build profile %s not found in %s", name, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{}
+	err = toml.Unmarshal(data, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing build profile %s: %v", name, err)
+	}
+	profile.Name = name
+
+	err = profile.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("build profile %s: %v", name, err)
+	}
+	return profile, nil
+}
+
+// SaveProfile writes the profile to dir under its name
+func SaveProfile(dir string, profile *Profile) error {
+	path, err := ProfilePath(dir, profile.Name)
+	if err != nil {
+		return err
+	}
+	err = profile.Validate()
+	if err != nil {
+		return err
+	}
+
+	data, err := toml.Marshal(*profile)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RemoveProfile deletes the named profile from dir
+func RemoveProfile(dir, name string) error {
+	path, err := ProfilePath(dir, name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("build profile %s not found", name)
+	}
+	return err
+}
+
+// ListProfiles returns the names of the profiles in dir
+func ListProfiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".toml") {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".toml"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Validate checks the backend name and that extra options are -D options
+func (p *Profile) Validate() error {
+	if p.Backend != "" {
+		if _, err := Lookup(p.Backend); err != nil {
+			return err
+		}
+	}
+	for _, option := range p.CMakeOptions {
+		if !strings.HasPrefix(option, "-D") || !strings.Contains(option, "=") {
+			return fmt.Errorf("invalid CMake option %q: use -DNAME=VALUE", option)
+		}
+	}
+	if p.Jobs < 0 {
+		return fmt.Errorf("invalid job count %d", p.Jobs)
+	}
+	return nil
+}
+
+// Override returns a copy of p with the fields set in o replacing its own.
+// Extra CMake options are added to the profile's rather than replacing them.
+func (p *Profile) Override(o *Profile) *Profile {
+	merged := *p
+	merged.CMakeOptions = append(append([]string{}, p.CMakeOptions...), o.CMakeOptions...)
+
+	if o.Backend != "" {
+		merged.Backend = o.Backend
+	}
+	if len(o.Targets) > 0 {
+		merged.Targets = o.Targets
+	}
+	if o.BuildType != "" {
+		merged.BuildType = o.BuildType
+	}
+	if o.Jobs != 0 {
+		merged.Jobs = o.Jobs
+	}
+	if o.CCompiler != "" {
+		merged.CCompiler = o.CCompiler
+	}
+	if o.CXXCompiler != "" {
+		merged.CXXCompiler = o.CXXCompiler
+	}
+	return &merged
+}
+
+// BuildTypeOrDefault returns the build type, Release unless set
+func (p *Profile) BuildTypeOrDefault() string {
+	if p.BuildType == "" {
+		return DefaultBuildType
+	}
+	return p.BuildType
+}
+
+// ConfigureOptions returns the CMake options for the build type, the
+// compilers and the extra options, in that order so extra options win
+func (p *Profile) ConfigureOptions() []string {
+	options := []string{"-DCMAKE_BUILD_TYPE=" + p.BuildTypeOrDefault()}
+	if p.CCompiler != "" {
+		options = append(options, "-DCMAKE_C_COMPILER="+p.CCompiler)
+	}
+	if p.CXXCompiler != "" {
+		options = append(options, "-DCMAKE_CXX_COMPILER="+p.CXXCompiler)
+	}
+	return append(options, p.CMakeOptions...)
+}
@@ -0,0 +1,54 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfileNames(t *testing.T) {
+	config := t.TempDir()
+	dir := filepath.Join(config, "build-profiles")
+	settings := filepath.Join(config, "settings.toml")
+	if err := os.WriteFile(settings, []byte("port = \"8080\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := &Profile{Name: "gpu", Backend: "cuda", Targets: []string{"rpc-server"}, Jobs: 4}
+	if err := SaveProfile(dir, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadProfile(dir, "gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("LoadProfile = %+v, want %+v", loaded, saved)
+	}
+
+	for _, name := range []string{"../settings", "../x", "/abs", filepath.Join(config, "settings"), "", ".hidden", "a/b"} {
+		if _, err := ProfilePath(dir, name); err == nil {
+			t.Errorf("ProfilePath(%q) succeeded", name)
+		}
+		if _, err := LoadProfile(dir, name); err == nil {
+			t.Errorf("LoadProfile(%q) succeeded", name)
+		}
+		if err := SaveProfile(dir, &Profile{Name: name}); err == nil {
+			t.Errorf("SaveProfile(%q) succeeded", name)
+		}
+		if err := RemoveProfile(dir, name); err == nil {
+			t.Errorf("RemoveProfile(%q) succeeded", name)
+		}
+	}
+	if _, err := os.Stat(settings); err != nil {
+		t.Fatalf("a file outside the profile directory was removed: %v", err)
+	}
+
+	if err := RemoveProfile(dir, "gpu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveProfile(dir, "gpu"); err == nil {
+		t.Error("removing a missing profile succeeded")
+	}
+}
@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// recordFile is kept in the build directory to describe how it was built
+const recordFile = "llamarunner-build.json"
+
+// Record describes how a build directory was configured
+type Record struct {
+	Backend      string   `json:"backend"`
+	Profile      string   `json:"profile,omitempty"`
+	CMakeOptions []string `json:"cmake_options"`
+}
+
+// RecordPath returns the record kept in a build directory
+func RecordPath(buildDir string) string {
+	return filepath.Join(buildDir, recordFile)
+}
+
+// SaveRecord writes the record into buildDir
+func SaveRecord(buildDir string, record *Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(RecordPath(buildDir), append(data, '\n'), 0644)
+}
+
+// LoadRecord reads the record of buildDir, returning nil if it has none
+func LoadRecord(buildDir string) (*Record, error) {
+	data, err := os.ReadFile(RecordPath(buildDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &Record{}
+	err = json.Unmarshal(data, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Matches reports whether the record was configured with exactly options
+func (r *Record) Matches(options []string) bool {
+	if len(r.CMakeOptions) != len(options) {
+		return false
+	}
+	for i := range options {
+		if r.CMakeOptions[i] != options[i] {
+			return false
+		}
+	}
+	return true
+}
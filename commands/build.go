@@ -2,11 +2,16 @@ package commands
 
 import (
 	"fmt"
+	"github/llamarunner/builder"
+	"github/llamarunner/hw"
 	"github/llamarunner/utils"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // BuildCommand implements the Command interface for building llama.cpp
@@ -19,33 +24,179 @@ func NewBuildCommand() *BuildCommand {
 	return &BuildCommand{
 		BaseCommand: NewBaseCommand(
 			"build",
-			"Builds llama.cpp with CUDA detection and optimizations",
-			"llamarunner build [directory]",
+			"Builds llama.cpp with GPU backend detection and optimizations",
+			"llamarunner build [directory] [--profile <name>] [--backend cuda|hip|sycl|vulkan|blas|cpu] [--target <name>]... [-D NAME=VALUE]... [--build-type <type>] [--jobs <n>] [--cc <compiler>] [--cxx <compiler>] [--clean] [--skip-checks]\n"+
+				"       llamarunner build profile [list | show <name> | save <name> [options] | rm <name>]\n"+
+				"       llamarunner build info [directory]\nOptions:\n"+
+				"  --profile     Start from this build profile instead of the build_profile setting\n"+
+				"  --backend     Build for this backend instead of the detected or previous one\n"+
+				"  --target      Build this CMake target instead of the default ones; repeat for more\n"+
+				"  -D            Pass an extra CMake option; repeat for more\n"+
+				"  --build-type  CMake build type (default: Release)\n"+
+				"  --jobs        Number of parallel compile jobs (default: all cores)\n"+
+				"  --cc, --cxx   C and C++ compilers\n"+
+				"  --clean       Reconfigure and rebuild everything instead of building incrementally\n"+
+				"  --skip-checks Build without checking the prerequisites first",
 		),
 	}
 }
 
+// BuildOptions controls how llama.cpp is built
+type BuildOptions struct {
+	// Profile names the build profile; empty uses the build_profile setting
+	Profile string
+
+	// Overrides are set from flags and take precedence over the profile. An
+	// empty backend keeps the previous build's backend or detects one.
+	Overrides builder.Profile
+
+	// Clean reconfigures from scratch and rebuilds every file
+	Clean bool
+
+	// SkipChecks builds without checking the prerequisites first
+	SkipChecks bool
+}
+
 // Run executes the build command
-func (c *BuildCommand) Run(args []string) {
+func (c *BuildCommand) Run(ctx *utils.Context, args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "profile":
+			c.runProfile(ctx, args[1:])
+			return
+		case "info":
+			c.runInfo(ctx, args[1:])
+			return
+		}
+	}
+
 	var buildDir string
+	var opts BuildOptions
 
-	if len(args) > 0 {
-		buildDir = args[0]
-	} else {
+	for i := 0; i < len(args); i++ {
+		n, err := parseProfileFlag(args, i, &opts.Overrides)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if n > 0 {
+			i += n - 1
+			continue
+		}
+
+		switch {
+		case args[i] == "--profile":
+			if i+1 >= len(args) {
+				fmt.Println("Missing value for --profile")
+				return
+			}
+			opts.Profile = args[i+1]
+			i++
+		case args[i] == "--clean":
+			opts.Clean = true
+		case args[i] == "--skip-checks":
+			opts.SkipChecks = true
+		case strings.HasPrefix(args[i], "-"):
+			fmt.Printf("Unknown option: %s\n", args[i])
+			fmt.Println(c.Usage())
+			return
+		default:
+			buildDir = args[i]
+		}
+	}
+
+	if buildDir == "" {
 		// Default to llama.cpp directory from settings
-		settings, err := utils.LoadSettings()
-		if err != nil || settings.LlamaCppPath == "" {
+		if ctx.Settings.LlamaCppPath == "" {
 			fmt.Println("Error: no installation directory specified and no default found in settings")
 			return
 		}
-		buildDir = settings.LlamaCppPath
+		buildDir = ctx.Dirs.ExpandPath(ctx.Settings.LlamaCppPath)
 	}
 
-	c.buildLlamaCpp(buildDir)
+	c.buildLlamaCpp(ctx, buildDir, opts)
+}
+
+// parseProfileFlag applies the profile flag at args[i] to profile. It
+// returns the number of arguments used, or 0 when args[i] isn't a profile
+// flag.
+func parseProfileFlag(args []string, i int, profile *builder.Profile) (int, error) {
+	arg := args[i]
+
+	// -DNAME=VALUE may be passed as one argument
+	if strings.HasPrefix(arg, "-D") && len(arg) > 2 {
+		profile.CMakeOptions = append(profile.CMakeOptions, arg)
+		return 1, nil
+	}
+
+	switch arg {
+	case "--backend", "--target", "-D", "--build-type", "--jobs", "--cc", "--cxx":
+	default:
+		return 0, nil
+	}
+
+	if i+1 >= len(args) {
+		return 0, fmt.Errorf("Missing value for %s", arg)
+	}
+	value := args[i+1]
+
+	switch arg {
+	case "--backend":
+		profile.Backend = value
+	case "--target":
+		for _, target := range strings.Split(value, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				profile.Targets = append(profile.Targets, target)
+			}
+		}
+	case "-D":
+		profile.CMakeOptions = append(profile.CMakeOptions, "-D"+value)
+	case "--build-type":
+		profile.BuildType = value
+	case "--jobs":
+		jobs, err := strconv.Atoi(value)
+		if err != nil || jobs < 1 {
+			return 0, fmt.Errorf("Invalid value for --jobs: %s", value)
+		}
+		profile.Jobs = jobs
+	case "--cc":
+		profile.CCompiler = value
+	case "--cxx":
+		profile.CXXCompiler = value
+	}
+	return 2, nil
+}
+
+// resolveBuildProfile loads the profile named in opts or by the
+// build_profile setting and applies the overrides from opts to it
+func resolveBuildProfile(ctx *utils.Context, opts BuildOptions) (*builder.Profile, error) {
+	name := opts.Profile
+	if name == "" {
+		name = ctx.Settings.BuildProfile
+	}
+
+	profile := &builder.Profile{}
+	if name != "" {
+		var err error
+		profile, err = builder.LoadProfile(ctx.Dirs.BuildProfiles(), name)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Using build profile %s\n", name)
+	}
+
+	profile = profile.Override(&opts.Overrides)
+	err := profile.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
 }
 
-// BuildLlamaCpp is an exported function that builds llama.cpp in the specified directory
-func BuildLlamaCpp(buildDir string) error {
+// BuildLlamaCpp is an exported function that builds llama.cpp in the
+// specified directory. Commands run in that directory; the process working
+// directory is left alone.
+func BuildLlamaCpp(ctx *utils.Context, buildDir string, opts BuildOptions) error {
 	fmt.Printf("Building llama.cpp in: %s\n", buildDir)
 
 	// Check if the directory exists
@@ -53,141 +204,229 @@ func BuildLlamaCpp(buildDir string) error {
 		return fmt.Errorf("directory %s does not exist", buildDir)
 	}
 
-	// Change to build directory
-	err := os.Chdir(buildDir)
+	// The previous build's configuration decides what needs redoing
+	previous, err := builder.LoadRecord(filepath.Join(buildDir, "build"))
 	if err != nil {
-		return fmt.Errorf("error changing directory: %v", err)
+		fmt.Printf("Warning: ignoring the previous build configuration: %v\n", err)
+		previous = nil
 	}
 
-	// Load settings
-	settings, err := utils.LoadSettings()
+	profile, err := resolveBuildProfile(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("error loading settings: %v", err)
+		return err
 	}
 
-	// Detect CUDA availability
-	hasCUDA, err := utils.HasCUDA()
+	backend, err := chooseBackend(ctx, profile.Backend, previous)
 	if err != nil {
-		return fmt.Errorf("error detecting CUDA: %v", err)
+		return err
+	}
+	fmt.Printf("Building for %s (%s)\n", backend.Name, backend.Description)
+
+	if !opts.SkipChecks {
+		ok := runPreflight(builder.PreflightOptions{
+			Env:         builder.SystemEnv(),
+			Dir:         buildDir,
+			Backend:     backend,
+			CCompiler:   profile.CCompiler,
+			CXXCompiler: profile.CXXCompiler,
+			Curl:        !containsString(profile.CMakeOptions, "-DLLAMA_CURL=OFF"),
+		})
+		if !ok {
+			return fmt.Errorf("missing build prerequisites; install them, or pass --skip-checks to build anyway")
+		}
 	}
 
-	fmt.Printf("CUDA detection: available=%t, forceCPU=%t\n", hasCUDA, settings.ForceCPU)
+	// Remember the checkout so "llamarunner du" can find its build later
+	err = utils.RecordCheckout(ctx, buildDir)
+	if err != nil {
+		fmt.Printf("Warning: could not record the checkout: %v\n", err)
+	}
 
-	// Determine build configuration
-	var shouldForceCPU bool
+	// Build with cmake
+	started := time.Now()
+	err = runCMakeBuild(buildDir, backend, profile, previous, opts.Clean)
+	if err != nil {
+		printBuildDiagnosis(builder.LogPath(filepath.Join(buildDir, "build")))
+		return fmt.Errorf("cmake build failed: %v", err)
+	}
 
-	if !hasCUDA && !settings.ForceCPU {
-		// No CUDA and not forcing CPU - prompt user
-		fmt.Print("CUDA not detected. Build with CPU only? (y/N) ")
+	// Copy binaries
+	err = copyBinaries(buildDir, profileTargets(profile))
+	if err != nil {
+		return fmt.Errorf("error copying binaries: %v", err)
+	}
 
-		var input string
-		fmt.Scanln(&input)
+	// Record what was built, so results can be traced back to this build
+	err = writeBuildManifest(buildDir, started)
+	if err != nil {
+		fmt.Printf("Warning: could not write the build manifest: %v\n", err)
+	}
 
-		input = strings.TrimSpace(strings.ToLower(input))
-		if input == "y" {
-			shouldForceCPU = true
+	fmt.Println("llama.cpp built successfully!")
+	return nil
+}
 
-			// Ask if they want to update the forcing policy
-			fmt.Print("Update settings to force CPU builds for future builds? (Y/n) ")
-			fmt.Scanln(&input)
+// chooseBackend returns the named backend, from a flag or the build profile,
+// or the previous build's backend, or detects one. When no GPU toolchain is
+// found the user confirms a CPU-only build, unless force_cpu is set.
+func chooseBackend(ctx *utils.Context, name string, previous *builder.Record) (*builder.Backend, error) {
+	env := builder.SystemEnv()
 
-			input = strings.TrimSpace(strings.ToLower(input))
-			if input != "n" && input != "" {
-				settings.ForceCPU = true
+	// The preflight check reports what a named backend is missing
+	if name != "" {
+		return builder.Lookup(name)
+	}
 
-				// Save updated settings
-				err = utils.SaveSettings(settings)
-				if err != nil {
-					return fmt.Errorf("error saving settings: %v", err)
-				}
+	settings := ctx.Settings
+	if settings.ForceCPU {
+		fmt.Println("force_cpu is set, building with CPU support only")
+		return builder.CPU(), nil
+	}
 
-				fmt.Println("Settings updated to force CPU builds.")
-			} else {
-				fmt.Println("Proceeding with CPU build for this session only.")
-			}
+	// Rebuilds keep the backend unless asked otherwise
+	if previous != nil {
+		backend, err := builder.Lookup(previous.Backend)
+		if err == nil && len(backend.Missing(env)) == 0 {
+			fmt.Printf("Using the %s backend of the previous build (pass --backend to change it)\n", backend.Name)
+			return backend, nil
+		}
+	}
 
-			fmt.Println("Building with CPU support only")
-		} else {
-			return fmt.Errorf("CUDA is required for full functionality. Use --force-cpu or set force_cpu=true in settings to build with CPU only")
+	inventory := hw.Detect(hw.SystemSource())
+	vendors := inventory.Vendors()
+	backend := builder.Detect(env, vendors)
+	if backend != builder.CPU() {
+		fmt.Printf("Detected backend: %s\n", backend.Name)
+		return backend, nil
+	}
+
+	// Point at the toolchain a GPU that is present would need
+	for _, candidate := range builder.Backends {
+		if candidate.Auto && candidate.Vendor != "" && inventory.HasVendor(candidate.Vendor) {
+			fmt.Printf("Found a %s GPU, but the %s backend needs %s: %s\n", candidate.Vendor, candidate.Name,
+				strings.Join(candidate.Missing(env), ", "), candidate.Hint)
 		}
-	} else if !hasCUDA || settings.ForceCPU {
-		// No CUDA available or forcing CPU
-		shouldForceCPU = true
-		fmt.Println("Building with CPU support only")
-	} else {
-		// CUDA is available and not forcing CPU
-		shouldForceCPU = false
-		fmt.Println("Building with CUDA support")
 	}
 
-	// Build with cmake
-	err = runCMakeBuild(shouldForceCPU)
-	if err != nil {
-		return fmt.Errorf("cmake build failed: %v", err)
+	// No GPU toolchain and not forcing CPU - prompt user
+	fmt.Print("No GPU toolchain (CUDA, ROCm, oneAPI or Vulkan) detected. Build with CPU only? (y/N) ")
+
+	var input string
+	fmt.Scanln(&input)
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input != "y" {
+		return nil, fmt.Errorf("no GPU backend available. Install a GPU toolchain, pass --backend cpu, or set force_cpu=true in settings to build with CPU only")
 	}
 
-	// Copy binaries
-	err = copyBinaries()
-	if err != nil {
-		return fmt.Errorf("error copying binaries: %v", err)
+	// Ask if they want to update the forcing policy
+	fmt.Print("Update settings to force CPU builds for future builds? (Y/n) ")
+	fmt.Scanln(&input)
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input != "n" && input != "" {
+		settings.ForceCPU = true
+
+		// Save updated settings
+		err := utils.SaveSettings(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error saving settings: %v", err)
+		}
+
+		fmt.Println("Settings updated to force CPU builds.")
+	} else {
+		fmt.Println("Proceeding with CPU build for this session only.")
 	}
 
-	fmt.Println("llama.cpp built successfully!")
-	return nil
+	return builder.CPU(), nil
 }
 
-// runCMakeBuild runs the cmake build process with appropriate flags
-func runCMakeBuild(forceCPU bool) error {
+// runCMakeBuild configures the build of the checkout at srcDir for backend
+// and profile unless the previous configuration already matches, records
+// the configuration in the build directory and builds the profile's targets
+// incrementally, or from scratch when clean is set. CMake's output is also
+// written to the build log.
+func runCMakeBuild(srcDir string, backend *builder.Backend, profile *builder.Profile, previous *builder.Record, clean bool) error {
 	// Create build directory
-	buildDir := "build"
+	buildDir := filepath.Join(srcDir, "build")
 	if err := os.MkdirAll(buildDir, 0755); err != nil {
 		return fmt.Errorf("error creating build directory: %v", err)
 	}
 
+	logFile, err := os.Create(builder.LogPath(buildDir))
+	if err != nil {
+		return fmt.Errorf("error creating build log: %v", err)
+	}
+	defer logFile.Close()
+	output := io.MultiWriter(os.Stdout, logFile)
+
 	// Prepare cmake arguments
-	cmakeArgs := []string{
-		"-B", buildDir,
+	options := append([]string{
 		"-DBUILD_SHARED_LIBS=OFF",
 		"-DLLAMA_CURL=ON",
-	}
+	}, backend.CMakeOptions()...)
 
-	if forceCPU {
-		cmakeArgs = append(cmakeArgs, "-DGGML_CUDA=OFF")
-		fmt.Println("CMAKE args: -DGGML_CUDA=OFF")
-	} else {
-		cmakeArgs = append(cmakeArgs,
-			"-DGGML_CUDA=ON",
-			"-DGGML_CUDA_FA_ALL_QUANTS=ON",
-		)
-		fmt.Println("CMAKE args: -DGGML_CUDA=ON -DGGML_CUDA_FA_ALL_QUANTS=ON")
+	// Cache compiler output so rebuilds after a pull are quick
+	launcher := builder.CompilerLauncher(builder.SystemEnv())
+	if launcher != "" {
+		fmt.Printf("Using %s to cache compiler output\n", launcher)
+		options = append(options, backend.LauncherOptions(launcher)...)
 	}
 
-	// Run cmake
-	fmt.Println("Running cmake...")
-	cmakeCmd := exec.Command("cmake", cmakeArgs...)
-	cmakeCmd.Stdout = os.Stdout
-	cmakeCmd.Stderr = os.Stderr
+	// The profile's options come last so they can override the ones above
+	options = append(options, profile.ConfigureOptions()...)
+
+	cache := filepath.Join(buildDir, "CMakeCache.txt")
+	switch {
+	case !clean && previous != nil && previous.Matches(options) && utils.FileExists(cache):
+		fmt.Println("CMake options unchanged, skipping configure")
+
+		// Another profile may configure the same options; the manifest
+		// names the profile from the record
+		if previous.Profile != profile.Name {
+			previous.Profile = profile.Name
+			err = builder.SaveRecord(buildDir, previous)
+			if err != nil {
+				return fmt.Errorf("error recording the build configuration: %v", err)
+			}
+		}
+	default:
+		// CMake keeps cached options that are no longer passed, so start
+		// from a fresh cache whenever the options change
+		if utils.FileExists(cache) {
+			if clean {
+				fmt.Println("Reconfiguring from scratch")
+			} else {
+				fmt.Println("CMake options changed, reconfiguring")
+			}
+			err := os.Remove(cache)
+			if err != nil {
+				return fmt.Errorf("error removing %s: %v", cache, err)
+			}
+		}
 
-	err := cmakeCmd.Run()
-	if err != nil {
-		return fmt.Errorf("cmake configuration failed: %v", err)
+		fmt.Printf("CMAKE args: %s\n", strings.Join(options, " "))
+
+		// Run cmake
+		fmt.Println("Running cmake...")
+		cmakeCmd := exec.Command("cmake", append([]string{"-B", "build"}, options...)...)
+		cmakeCmd.Dir = srcDir
+		cmakeCmd.Stdout = output
+		cmakeCmd.Stderr = output
+
+		err := cmakeCmd.Run()
+		if err != nil {
+			return fmt.Errorf("cmake configuration failed: %v", err)
+		}
+
+		err = builder.SaveRecord(buildDir, &builder.Record{Backend: backend.Name, Profile: profile.Name, CMakeOptions: options})
+		if err != nil {
+			return fmt.Errorf("error recording the build configuration: %v", err)
+		}
 	}
 
 	// Build the targets
-	fmt.Println("Building llama-cli and llama-gguf-split...")
-	buildCmd := exec.Command("cmake",
-		"--build", buildDir,
-		"--config", "Release",
-		"-j",
-		"--clean-first",
-		"--target", "llama-cli",
-		"--target", "llama-gguf-split",
-		"--target", "llama-server",
-	)
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-
-	err = buildCmd.Run()
+	err = buildTargets(srcDir, profile, clean, output)
 	if err != nil {
 		return fmt.Errorf("cmake build failed: %v", err)
 	}
@@ -195,40 +434,223 @@ func runCMakeBuild(forceCPU bool) error {
 	return nil
 }
 
-// copyBinaries is disabled to keep binaries in build/bin directory
-func copyBinaries() error {
+// writeBuildManifest records the source, configuration, compiler, host CPU
+// and binaries of the build of the checkout at srcDir. The backend and
+// profile are those the build directory was configured with.
+func writeBuildManifest(srcDir string, started time.Time) error {
+	buildDir := filepath.Join(srcDir, "build")
+
+	record, err := builder.LoadRecord(buildDir)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("%s is missing", builder.RecordPath(buildDir))
+	}
+
+	cpu := hw.DetectCPU(hw.SystemSource())
+	manifest := &builder.Manifest{
+		Backend:      record.Backend,
+		Profile:      record.Profile,
+		CMakeOptions: record.CMakeOptions,
+		Compiler:     builder.CompilerVersion(buildDir),
+		Finished:     time.Now(),
+		Duration:     time.Since(started).Seconds(),
+		CPUFlags:     cpu.Features(),
+	}
+	manifest.ReadGit(srcDir)
+
+	manifest.Binaries, err = builder.ScanBinaries(filepath.Join(buildDir, "bin"))
+	if err != nil {
+		return err
+	}
+
+	err = builder.SaveManifest(buildDir, manifest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Build manifest written to %s\n", builder.ManifestPath(buildDir))
+	return nil
+}
+
+// printBuildDiagnosis explains the known causes of failure found in the
+// build log at logPath and points at the full log
+func printBuildDiagnosis(logPath string) {
+	diagnoses, err := builder.DiagnoseLog(logPath)
+	if err != nil {
+		return
+	}
+
+	for _, diagnosis := range diagnoses {
+		fmt.Printf("\n%s\n  Fix: %s\n", diagnosis.Problem, diagnosis.Fix)
+	}
+	if len(diagnoses) == 0 {
+		fmt.Println("\nThe build failed for a reason llamarunner doesn't recognize; the errors are above.")
+	}
+	fmt.Printf("Full build log: %s\n\n", logPath)
+}
+
+// defaultBuildTargets are the binaries built by "llamarunner build" when the
+// profile names none
+var defaultBuildTargets = []string{"llama-cli", "llama-gguf-split", "llama-server"}
+
+// profileTargets returns the cmake targets the profile builds
+func profileTargets(profile *builder.Profile) []string {
+	if len(profile.Targets) == 0 {
+		return defaultBuildTargets
+	}
+	return profile.Targets
+}
+
+// buildTargets builds the profile's cmake targets in the configured build
+// directory of the llama.cpp checkout at srcDir, writing CMake's output to
+// output
+func buildTargets(srcDir string, profile *builder.Profile, clean bool, output io.Writer) error {
+	targets := profileTargets(profile)
+	fmt.Printf("Building %s...\n", strings.Join(targets, ", "))
+
+	args := []string{"--build", "build", "--config", profile.BuildTypeOrDefault(), "-j"}
+	if profile.Jobs > 0 {
+		args = append(args, strconv.Itoa(profile.Jobs))
+	}
+	if clean {
+		args = append(args, "--clean-first")
+	}
+	for _, target := range targets {
+		args = append(args, "--target", target)
+	}
+
+	buildCmd := exec.Command("cmake", args...)
+	buildCmd.Dir = srcDir
+	buildCmd.Stdout = output
+	buildCmd.Stderr = output
+
+	return buildCmd.Run()
+}
+
+// ensureLlamaBinary returns the path of a llama.cpp tool such as
+// llama-quantize, building its target first if it is missing. The build
+// directory must already be configured by "llamarunner build".
+func ensureLlamaBinary(ctx *utils.Context, name string) (string, error) {
+	path := utils.LlamaBinaryPath(ctx, name)
+	if utils.FileExists(path) {
+		return path, nil
+	}
+
+	srcDir := ctx.Dirs.ExpandPath(utils.FindLlamaCppDir(ctx))
+	if !utils.FileExists(filepath.Join(srcDir, "build", "CMakeCache.txt")) {
+		return "", fmt.Errorf("%s not found and llama.cpp in %s is not configured; run 'llamarunner build' first", name, srcDir)
+	}
+
+	fmt.Printf("%s not found, building it...\n", name)
+	// Add to the log of the full build rather than replacing it
+	logPath := builder.LogPath(filepath.Join(srcDir, "build"))
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("error opening build log: %v", err)
+	}
+	defer logFile.Close()
+	fmt.Fprintf(logFile, "\n=== Building %s at %s ===\n", name, time.Now().Format(time.RFC3339))
+
+	err = buildTargets(srcDir, &builder.Profile{Targets: []string{name}}, false, io.MultiWriter(os.Stdout, logFile))
+	if err != nil {
+		printBuildDiagnosis(logPath)
+		return "", fmt.Errorf("error building %s: %v", name, err)
+	}
+
+	if !utils.FileExists(path) {
+		return "", fmt.Errorf("build finished but %s is missing", path)
+	}
+
+	err = addToBuildManifest(srcDir, path)
+	if err != nil {
+		fmt.Printf("Warning: could not update the build manifest: %v\n", err)
+	}
+	return path, nil
+}
+
+// addToBuildManifest records a binary built on demand in the manifest of
+// the build of the checkout at srcDir. The rest of the manifest still
+// describes the full build; a build without a manifest is left without one.
+func addToBuildManifest(srcDir, path string) error {
+	buildDir := filepath.Join(srcDir, "build")
+	manifest, err := builder.LoadManifest(buildDir)
+	if err != nil || manifest == nil {
+		return err
+	}
+
+	binary, err := builder.HashBinary(path)
+	if err != nil {
+		return err
+	}
+
+	// The checkout may have moved on since the full build
+	current := &builder.Manifest{}
+	current.ReadGit(srcDir)
+	if current.Commit != manifest.Commit {
+		binary.Commit = current.Commit
+	}
+
+	manifest.SetBinary(binary)
+	return builder.SaveManifest(buildDir, manifest)
+}
+
+// llamaCppCommit returns the git commit of the llama.cpp checkout, or
+// "unknown" when it can't be determined
+func llamaCppCommit(ctx *utils.Context) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = ctx.Dirs.ExpandPath(utils.FindLlamaCppDir(ctx))
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// copyBinaries is disabled to keep binaries in the build/bin directory of
+// the checkout at dir; it only checks that the targets were built
+func copyBinaries(dir string, targets []string) error {
 	fmt.Println("Keeping binaries in build/bin directory (not copying to parent)")
 
 	// Source directory (build output)
-	srcDir := "build/bin"
+	srcDir := filepath.Join(dir, "build", "bin")
 
 	// Check if source directory exists
 	if _, err := os.Stat(srcDir); os.IsNotExist(err) {
 		return fmt.Errorf("build output directory not found: %s", srcDir)
 	}
 
-	// Find all llama-* binaries to verify they exist
-	binaries, err := filepath.Glob(filepath.Join(srcDir, "llama-*"))
-	if err != nil {
-		return fmt.Errorf("error finding binaries: %v", err)
+	// Verify the binaries of the built targets exist
+	var found, missing []string
+	for _, target := range targets {
+		if utils.FileExists(filepath.Join(srcDir, target)) {
+			found = append(found, target)
+		} else {
+			missing = append(missing, target)
+		}
 	}
 
-	if len(binaries) == 0 {
-		return fmt.Errorf("no llama-* binaries found in %s", srcDir)
+	if len(found) == 0 {
+		return fmt.Errorf("none of %s found in %s", strings.Join(targets, ", "), srcDir)
 	}
 
 	fmt.Printf("Binaries available in %s:\n", srcDir)
-	for _, binary := range binaries {
-		binaryName := filepath.Base(binary)
+	for _, binaryName := range found {
 		fmt.Printf("  - %s\n", binaryName)
 	}
 
+	// Targets such as libraries don't produce a program
+	if len(missing) > 0 {
+		fmt.Printf("Warning: no binary for %s in %s\n", strings.Join(missing, ", "), srcDir)
+	}
+
 	return nil
 }
 
 // buildLlamaCpp handles the building of llama.cpp (internal method)
-func (c *BuildCommand) buildLlamaCpp(buildDir string) {
-	err := BuildLlamaCpp(buildDir)
+func (c *BuildCommand) buildLlamaCpp(ctx *utils.Context, buildDir string, opts BuildOptions) {
+	err := BuildLlamaCpp(ctx, buildDir, opts)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github/llamarunner/builder"
+	"github/llamarunner/utils"
+)
+
+// runInfo shows the manifest of the build in the given or configured
+// llama.cpp checkout
+func (c *BuildCommand) runInfo(ctx *utils.Context, args []string) {
+	if len(args) > 1 || (len(args) == 1 && strings.HasPrefix(args[0], "-")) {
+		fmt.Println(c.Usage())
+		return
+	}
+
+	srcDir := ctx.Dirs.ExpandPath(utils.FindLlamaCppDir(ctx))
+	if len(args) == 1 {
+		srcDir = ctx.Dirs.ExpandPath(args[0])
+	}
+	buildDir := filepath.Join(srcDir, "build")
+
+	manifest, err := builder.LoadManifest(buildDir)
+	if err != nil {
+		fmt.Printf("Error reading the build manifest: %v\n", err)
+		return
+	}
+	if manifest == nil {
+		fmt.Printf("No build manifest in %s; run 'llamarunner build' to create one\n", buildDir)
+		return
+	}
+
+	fmt.Printf("Build:          %s\n", buildDir)
+	printBuildManifest(manifest)
+
+	fmt.Println("Binaries:")
+	for _, binary := range manifest.Binaries {
+		fmt.Printf("  %-28s %10s  sha256:%s\n", binary.Name, utils.FormatBytes(binary.Size), binary.SHA256)
+		if binary.Commit != "" {
+			fmt.Printf("  %-28s built later from commit %s\n", "", binary.Commit)
+		}
+	}
+}
+
+// printBuildManifest prints the source, configuration and host of a build
+func printBuildManifest(manifest *builder.Manifest) {
+	commit := manifest.Commit
+	if manifest.Dirty {
+		commit += " (with uncommitted changes)"
+	}
+	ref := manifest.Branch
+	if manifest.Tag != "" {
+		ref = strings.TrimPrefix(ref+", tag "+manifest.Tag, ", ")
+	}
+	if ref == "" {
+		ref = "detached"
+	}
+	backend := manifest.Backend
+	if manifest.Profile != "" {
+		backend += " (profile " + manifest.Profile + ")"
+	}
+	duration := time.Duration(manifest.Duration * float64(time.Second)).Round(time.Second)
+
+	fmt.Printf("Commit:         %s\n", commit)
+	fmt.Printf("Branch:         %s\n", ref)
+	fmt.Printf("Backend:        %s\n", backend)
+	fmt.Printf("Compiler:       %s\n", manifest.Compiler)
+	fmt.Printf("Built:          %s in %s\n", manifest.Finished.Local().Format("2006-01-02 15:04:05"), duration)
+	fmt.Printf("CPU flags:      %s\n", strings.Join(manifest.CPUFlags, " "))
+	fmt.Printf("CMake options:  %s\n", strings.Join(manifest.CMakeOptions, " "))
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github/llamarunner/builder"
+	"github/llamarunner/utils"
+)
+
+// runProfile lists, shows, saves and removes build profiles
+func (c *BuildCommand) runProfile(ctx *utils.Context, args []string) {
+	dir := ctx.Dirs.BuildProfiles()
+
+	if len(args) == 0 || args[0] == "list" {
+		listBuildProfiles(ctx, dir)
+		return
+	}
+
+	switch args[0] {
+	case "show":
+		if len(args) != 2 {
+			fmt.Println(c.Usage())
+			return
+		}
+		profile, err := builder.LoadProfile(dir, args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printBuildProfile(profile)
+	case "save":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			fmt.Println(c.Usage())
+			return
+		}
+		profile := &builder.Profile{Name: args[1]}
+		for i := 2; i < len(args); i++ {
+			n, err := parseProfileFlag(args, i, profile)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if n == 0 {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println(c.Usage())
+				return
+			}
+			i += n - 1
+		}
+
+		err := builder.SaveProfile(dir, profile)
+		if err != nil {
+			fmt.Printf("Error saving build profile: %v\n", err)
+			return
+		}
+		path, _ := builder.ProfilePath(dir, profile.Name)
+		fmt.Printf("Saved build profile %s to %s\n", profile.Name, path)
+		if ctx.Settings.BuildProfile != profile.Name {
+			fmt.Printf("Use it with 'llamarunner build --profile %s', or set build_profile = \"%s\" to make it the default\n", profile.Name, profile.Name)
+		}
+	case "rm":
+		if len(args) != 2 {
+			fmt.Println(c.Usage())
+			return
+		}
+		err := builder.RemoveProfile(dir, args[1])
+		if err != nil {
+			fmt.Printf("Error removing build profile: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed build profile %s\n", args[1])
+		if ctx.Settings.BuildProfile == args[1] {
+			fmt.Println("Warning: build_profile still names it; builds will fail until you change the setting")
+		}
+	default:
+		fmt.Printf("Unknown profile command: %s\n", args[0])
+		fmt.Println(c.Usage())
+	}
+}
+
+// listBuildProfiles prints the saved profiles, marking the default one
+func listBuildProfiles(ctx *utils.Context, dir string) {
+	names, err := builder.ListProfiles(dir)
+	if err != nil {
+		fmt.Printf("Error listing build profiles: %v\n", err)
+		return
+	}
+	if len(names) == 0 {
+		fmt.Printf("No build profiles in %s\n", dir)
+		return
+	}
+
+	for _, name := range names {
+		marker := " "
+		if name == ctx.Settings.BuildProfile {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+}
+
+// printBuildProfile prints the settings of a profile, showing the defaults
+// used for the ones it leaves unset
+func printBuildProfile(profile *builder.Profile) {
+	orDefault := func(value, fallback string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	}
+
+	targets := strings.Join(profile.Targets, ", ")
+	jobs := "all cores"
+	if profile.Jobs > 0 {
+		jobs = fmt.Sprint(profile.Jobs)
+	}
+
+	fmt.Printf("Profile:        %s\n", profile.Name)
+	fmt.Printf("Backend:        %s\n", orDefault(profile.Backend, "previous or detected"))
+	fmt.Printf("Targets:        %s\n", orDefault(targets, strings.Join(defaultBuildTargets, ", ")))
+	fmt.Printf("Build type:     %s\n", profile.BuildTypeOrDefault())
+	fmt.Printf("Jobs:           %s\n", jobs)
+	fmt.Printf("C compiler:     %s\n", orDefault(profile.CCompiler, "system default"))
+	fmt.Printf("C++ compiler:   %s\n", orDefault(profile.CXXCompiler, "system default"))
+	fmt.Printf("CMake options:  %s\n", orDefault(strings.Join(profile.CMakeOptions, " "), "none"))
+}
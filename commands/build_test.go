@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github/llamarunner/builder"
+)
+
+func TestCopyBinaries(t *testing.T) {
+	tests := []struct {
+		name    string
+		built   []string
+		targets []string
+		ok      bool
+	}{
+		{"default targets", []string{"llama-cli", "llama-gguf-split", "llama-server"}, nil, true},
+		{"rpc-server only", []string{"rpc-server"}, []string{"rpc-server"}, true},
+		{"library among the targets", []string{"llama-bench"}, []string{"llama-bench", "ggml"}, true},
+		{"nothing built", nil, []string{"rpc-server"}, false},
+		{"other binaries only", []string{"llama-cli"}, []string{"rpc-server"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			bin := filepath.Join(dir, "build", "bin")
+			if err := os.MkdirAll(bin, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.built {
+				if err := os.WriteFile(filepath.Join(bin, name), nil, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := copyBinaries(dir, profileTargets(&builder.Profile{Targets: tt.targets}))
+			if (err == nil) != tt.ok {
+				t.Errorf("copyBinaries = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+
+	if err := copyBinaries(t.TempDir(), defaultBuildTargets); err == nil {
+		t.Error("copyBinaries succeeded without a build directory")
+	}
+}
+
+func TestAddToBuildManifest(t *testing.T) {
+	srcDir := t.TempDir()
+	buildDir := filepath.Join(srcDir, "build")
+	bin := filepath.Join(buildDir, "bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"llama-cli", "llama-quantize"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a manifest there is nothing to add to
+	if err := addToBuildManifest(srcDir, filepath.Join(bin, "llama-quantize")); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, _ := builder.LoadManifest(buildDir); manifest != nil {
+		t.Fatal("a manifest was created")
+	}
+
+	finished := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	original := &builder.Manifest{
+		Commit:   "0123456789abcdef",
+		Backend:  "cuda",
+		Finished: finished,
+		Duration: 600,
+		Binaries: []builder.Binary{{Name: "llama-server", Size: 1, SHA256: "recorded"}},
+	}
+	if err := builder.SaveManifest(buildDir, original); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"llama-quantize", "llama-cli", "llama-quantize"} {
+		if err := addToBuildManifest(srcDir, filepath.Join(bin, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, err := builder.LoadManifest(buildDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Commit != original.Commit || !manifest.Finished.Equal(finished) || manifest.Duration != 600 || manifest.Backend != "cuda" {
+		t.Errorf("the full build's record changed: %+v", manifest)
+	}
+
+	var names []string
+	for _, binary := range manifest.Binaries {
+		names = append(names, binary.Name)
+	}
+	if want := []string{"llama-cli", "llama-quantize", "llama-server"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("binaries = %q, want %q", names, want)
+	}
+	if server := manifest.Binary("llama-server"); server.SHA256 != "recorded" || server.Commit != "" {
+		t.Errorf("llama-server = %+v, want it unchanged", server)
+	}
+	quantize := manifest.Binary("llama-quantize")
+	if quantize.SHA256 == "" || quantize.Commit == "" || quantize.Commit == original.Commit {
+		t.Errorf("llama-quantize = %+v, want a hash and the commit it was built from", quantize)
+	}
+}
@@ -1,9 +1,11 @@
 package commands
 
+import "github/llamarunner/utils"
+
 // Command interface defines the standard interface for all commands
 type Command interface {
-	// Execute the command with given arguments
-	Run(args []string)
+	// Execute the command with the loaded context and given arguments
+	Run(ctx *utils.Context, args []string)
 
 	// Get the name of the command
 	Name() string
@@ -0,0 +1,451 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github/llamarunner/builder"
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// DuCommand implements the Command interface for the disk usage report
+type DuCommand struct {
+	*BaseCommand
+}
+
+// NewDuCommand creates a new du command
+func NewDuCommand() *DuCommand {
+	return &DuCommand{
+		BaseCommand: NewBaseCommand(
+			"du",
+			"Show disk space used by builds, models, logs and caches",
+			"llamarunner du [--prune]\nOptions:\n  --prune    Choose unused builds, models and caches to remove",
+		),
+	}
+}
+
+// duItem is one line of the report
+type duItem struct {
+	section string
+	label   string
+	size    int64
+	note    string
+
+	// remove deletes the item; nil when it can't be pruned
+	remove func() error
+}
+
+// diskUsage measures files, counting each inode once so hard links between
+// model directories and the store are not counted twice
+type diskUsage struct {
+	seen map[[2]uint64]bool
+}
+
+// size returns the space used by path and everything below it that was not
+// already counted. Symlinks are not followed.
+func (d *diskUsage) size(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			key := [2]uint64{uint64(stat.Dev), stat.Ino}
+			if d.seen[key] {
+				return nil
+			}
+			d.seen[key] = true
+			total += stat.Blocks * 512
+			return nil
+		}
+		total += info.Size()
+		return nil
+	})
+	return total
+}
+
+// Run executes the du command
+func (c *DuCommand) Run(ctx *utils.Context, args []string) {
+	prune := false
+	for _, arg := range args {
+		switch arg {
+		case "--prune":
+			prune = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println(c.Usage())
+			return
+		}
+	}
+
+	usage := &diskUsage{seen: map[[2]uint64]bool{}}
+	var items []duItem
+	items = append(items, buildUsage(ctx, usage)...)
+
+	models, err := modelUsage(ctx, usage)
+	if err != nil {
+		fmt.Printf("Error scanning models: %v\n", err)
+		return
+	}
+	items = append(items, models...)
+	items = append(items, dirUsage(ctx, usage)...)
+
+	prunable := printDiskUsage(items, prune)
+	if !prune {
+		return
+	}
+	if len(prunable) == 0 {
+		fmt.Println("\nNothing to prune")
+		return
+	}
+
+	fmt.Print("\nRemove which items? (e.g. 1 3-5, 'all', empty to cancel): ")
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	selected, err := parseSelection(input, len(prunable))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(selected) == 0 {
+		fmt.Println("Nothing removed")
+		return
+	}
+
+	var freed int64
+	for _, n := range selected {
+		item := prunable[n-1]
+		err := item.remove()
+		if err != nil {
+			fmt.Printf("Error removing %s: %v\n", item.label, err)
+			continue
+		}
+		fmt.Printf("Removed %s\n", item.label)
+		freed += item.size
+	}
+	fmt.Printf("Freed %s\n", utils.FormatBytes(freed))
+}
+
+// buildUsage reports each llama.cpp checkout and its build directories.
+// Checkouts other than the configured one are old builds.
+func buildUsage(ctx *utils.Context, usage *diskUsage) []duItem {
+	const section = "llama.cpp checkouts and builds"
+	configured, _ := filepath.Abs(ctx.Dirs.ExpandPath(utils.FindLlamaCppDir(ctx)))
+
+	var items []duItem
+	for _, checkout := range utils.KnownCheckouts(ctx) {
+		inUse := checkout == configured
+
+		// Measure builds first so the source size excludes them
+		var builds []duItem
+		for _, build := range utils.CheckoutBuildDirs(checkout) {
+			build := build
+			item := duItem{section: section, label: build, size: usage.size(build), remove: func() error { return os.RemoveAll(build) }}
+			switch {
+			case inUse && filepath.Base(build) == "build":
+				item.note = "in use"
+				item.remove = nil
+			case inUse:
+				item.note = "not used by llamarunner"
+			default:
+				item.note = "old build"
+			}
+			if record, err := builder.LoadRecord(build); err == nil && record != nil {
+				item.note = strings.TrimPrefix(item.note+", "+record.Backend, ", ")
+			}
+			builds = append(builds, item)
+		}
+
+		source := duItem{section: section, label: checkout + " (source)", size: usage.size(checkout)}
+		if inUse {
+			source.note = "configured"
+		} else {
+			source.note = "not configured"
+			source.remove = func() error { return os.RemoveAll(checkout) }
+		}
+		items = append(items, source)
+		items = append(items, builds...)
+	}
+	return items
+}
+
+// modelUsage reports each model, marking those no preset uses, the store
+// and incomplete downloads
+func modelUsage(ctx *utils.Context, usage *diskUsage) ([]duItem, error) {
+	const section = "Models"
+
+	index, err := library.Scan(ctx, library.ScanOptions{NoHash: true})
+	if err != nil {
+		return nil, err
+	}
+	refs, _, err := library.PresetReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shards are reported together as one model
+	var models []string
+	used := map[string]bool{}
+	for _, entry := range index.Entries {
+		// Commands given an alias index its blob; aliases are listed below
+		if library.IsBlob(ctx, entry.Path) {
+			continue
+		}
+
+		model := entry.Path
+		if shard, ok := gguf.ParseShardName(model); ok {
+			model = gguf.ShardPath(shard.Prefix, 1, shard.Count)
+		}
+		if _, ok := used[model]; !ok {
+			models = append(models, model)
+			used[model] = false
+		}
+		if len(refs[entry.Path]) > 0 {
+			used[model] = true
+		}
+	}
+
+	var items []duItem
+	for _, model := range models {
+		model := model
+		files := gguf.ShardPaths(model)
+		item := duItem{section: section, label: displayModelPath(ctx, model)}
+		for _, file := range files {
+			item.size += usage.size(file)
+			item.size += usage.size(library.ProvenancePath(file))
+		}
+		if !used[model] {
+			item.note = "unused"
+
+			// A preset may have started using it since the report
+			item.remove = func() error {
+				_, err := deleteModelFiles(ctx, model, false)
+				return err
+			}
+		}
+		items = append(items, item)
+	}
+
+	aliases, err := library.ListAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		alias := alias
+		item := duItem{section: section, label: "alias " + alias.Name}
+		for _, blob := range alias.Blobs {
+			item.size += usage.size(blob)
+		}
+		if presets, err := library.AliasPresets(ctx, alias.Name); err == nil && len(presets) == 0 {
+			item.note = "unused"
+
+			// Free the blobs no other alias shares, as the size promised
+			item.remove = func() error {
+				_, err := deleteAlias(ctx, alias.Name, false)
+				if err != nil {
+					return err
+				}
+				return library.RemoveUnusedBlobs(ctx, alias.Blobs)
+			}
+		}
+		items = append(items, item)
+	}
+
+	garbage, _, err := library.CollectGarbage(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(garbage) > 0 {
+		item := duItem{section: section, label: "store files no alias uses", note: "unused"}
+		for _, path := range garbage {
+			item.size += usage.size(path)
+		}
+		item.remove = func() error {
+			_, _, err := library.CollectGarbage(ctx, false)
+			return err
+		}
+		items = append(items, item)
+	}
+
+	// Interrupted pulls leave .part files next to the model
+	for _, dir := range utils.ModelDirs(ctx) {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".part") {
+				return nil
+			}
+			files := []string{path, path + ".json"}
+			items = append(items, duItem{
+				section: section,
+				label:   displayModelPath(ctx, path),
+				size:    usage.size(path) + usage.size(path+".json"),
+				note:    "incomplete download",
+				remove:  func() error { return removeFiles(ctx, files) },
+			})
+			return nil
+		})
+	}
+
+	return items, nil
+}
+
+// dirUsage reports the caches, logs and other data kept by llamarunner
+func dirUsage(ctx *utils.Context, usage *diskUsage) []duItem {
+	var items []duItem
+
+	// Caches that can't be recreated cheaply are only reported: the index
+	// takes a rescan with hashing, importance matrices hours of compute,
+	// and the wheels are supplied by the user
+	kept := map[string]string{
+		library.IndexPath(ctx):  "",
+		library.IMatrixDir(ctx): "computed by model imatrix",
+		ctx.Dirs.Wheels():       "wheels for model convert",
+	}
+	for _, path := range dirEntries(ctx.Dirs.Cache) {
+		path := path
+		item := duItem{section: "Caches", label: path, size: usage.size(path)}
+		if note, ok := kept[path]; ok {
+			item.note = note
+		} else {
+			item.remove = func() error { return os.RemoveAll(path) }
+		}
+		items = append(items, item)
+	}
+
+	for _, path := range dirEntries(ctx.Dirs.State) {
+		path := path
+		item := duItem{section: "Logs and state", label: path, size: usage.size(path)}
+		if strings.HasSuffix(path, ".log") || filepath.Base(path) == "logs" {
+			item.remove = func() error { return os.RemoveAll(path) }
+		}
+		items = append(items, item)
+	}
+
+	if venv := ctx.Dirs.Venv(); utils.FileExists(venv) {
+		items = append(items, duItem{
+			section: "Other data",
+			label:   venv,
+			size:    usage.size(venv),
+			note:    "recreated by model convert",
+			remove:  func() error { return os.RemoveAll(venv) },
+		})
+	}
+	if datasets := ctx.Dirs.Datasets(); utils.FileExists(datasets) {
+		items = append(items, duItem{section: "Other data", label: datasets, size: usage.size(datasets)})
+	}
+
+	return items
+}
+
+// dirEntries returns the paths of the entries in dir, sorted
+func dirEntries(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return paths
+}
+
+// removeFiles deletes the files of an incomplete download along with
+// their provenance files and drops them from the index
+func removeFiles(ctx *utils.Context, files []string) error {
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err = os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(library.ProvenancePath(file))
+		index.Remove(file)
+	}
+	return index.Save()
+}
+
+// printDiskUsage prints the report by section and returns the prunable
+// items, numbered in the order shown when numbered is set
+func printDiskUsage(items []duItem, numbered bool) []duItem {
+	var prunable []duItem
+	var total, reclaimable int64
+	section := ""
+
+	for _, item := range items {
+		if item.section != section {
+			section = item.section
+			fmt.Printf("\n%s\n", section)
+		}
+
+		number := ""
+		if item.remove != nil {
+			prunable = append(prunable, item)
+			reclaimable += item.size
+			if numbered {
+				number = fmt.Sprintf("[%d]", len(prunable))
+			}
+		}
+		fmt.Printf("  %5s %10s  %s", number, utils.FormatBytes(item.size), item.label)
+		if item.note != "" {
+			fmt.Printf("  (%s)", item.note)
+		}
+		fmt.Println()
+		total += item.size
+	}
+
+	fmt.Printf("\nTotal: %s, of which %s can be pruned\n", utils.FormatBytes(total), utils.FormatBytes(reclaimable))
+	return prunable
+}
+
+// parseSelection parses item numbers such as "1 3-5,7" or "all" from 1 to
+// max, returning them sorted without duplicates
+func parseSelection(input string, max int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "all" {
+		input = fmt.Sprintf("1-%d", max)
+	}
+
+	selected := map[int]bool{}
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		if !isRange {
+			to = from
+		}
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || first < 1 || last > max || first > last {
+			return nil, fmt.Errorf("invalid selection %q: choose between 1 and %d", field, max)
+		}
+		for n := first; n <= last; n++ {
+			selected[n] = true
+		}
+	}
+
+	var numbers []int
+	for n := range selected {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// Register the du command automatically
+func init() {
+	RegisterCommand("du", NewDuCommand())
+}
@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// duContext returns a context for a temporary home without XDG variables
+func duContext(t *testing.T) *utils.Context {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(name, "")
+	}
+	ctx, err := utils.NewContext(home, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestModelUsageMalformed(t *testing.T) {
+	ctx := duContext(t)
+
+	// A header claiming a huge array, and a file that isn't GGUF at all
+	var huge bytes.Buffer
+	huge.WriteString("GGUF")
+	for _, field := range []interface{}{uint32(3), uint64(0), uint64(1), uint64(1), byte('k'), uint32(9), uint32(9), uint64(1 << 60)} {
+		binary.Write(&huge, binary.LittleEndian, field)
+	}
+
+	models := ctx.Dirs.Models()
+	files := map[string][]byte{"huge.gguf": huge.Bytes(), "junk.gguf": []byte("junk"), "empty.gguf": nil}
+	if err := os.MkdirAll(models, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(models, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := modelUsage(ctx, &diskUsage{seen: map[[2]uint64]bool{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(files) {
+		t.Fatalf("got %d items, want %d: %+v", len(items), len(files), items)
+	}
+
+	// Unused files can be pruned, through the same checks as model rm
+	for _, item := range items {
+		if item.note != "unused" || item.remove == nil {
+			t.Fatalf("%s is not prunable: %q", item.label, item.note)
+		}
+		if err := item.remove(); err != nil {
+			t.Fatalf("removing %s: %v", item.label, err)
+		}
+	}
+	for name := range files {
+		if utils.FileExists(filepath.Join(models, name)) {
+			t.Errorf("%s still exists", name)
+		}
+	}
+}
+
+func TestModelUsageAlias(t *testing.T) {
+	ctx := duContext(t)
+	model := filepath.Join(ctx.Dirs.Models(), "tiny.gguf")
+	if err := os.MkdirAll(filepath.Dir(model), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(model, []byte("model data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	alias, err := library.AddAlias(ctx, "tiny", model, library.StoreOptions{Move: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands run on the alias, such as model imatrix, index its blob
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.Add(alias.Files[0], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := modelUsage(ctx, &diskUsage{seen: map[[2]uint64]bool{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].label != "alias tiny" || items[0].size == 0 {
+		t.Fatalf("items = %+v, want only the alias", items)
+	}
+
+	for _, path := range []string{alias.Blobs[0], alias.Files[0]} {
+		if _, err := deleteModelFiles(ctx, path, true); err == nil {
+			t.Errorf("deleteModelFiles(%s) succeeded", path)
+		}
+	}
+	if _, err := library.LoadAlias(ctx, "tiny"); err != nil {
+		t.Fatalf("alias broken: %v", err)
+	}
+
+	// Pruning the unused alias frees its blob
+	if err := items[0].remove(); err != nil {
+		t.Fatal(err)
+	}
+	if utils.FileExists(alias.Blobs[0]) {
+		t.Error("blob of the pruned alias still exists")
+	}
+}
+
+func TestDirUsageCaches(t *testing.T) {
+	ctx := duContext(t)
+	prunable := map[string]bool{
+		library.IMatrixDir(ctx):                   false,
+		ctx.Dirs.Wheels():                         false,
+		filepath.Join(ctx.Dirs.Cache, "hub"):      true,
+		filepath.Join(ctx.Dirs.Cache, "old.json"): true,
+	}
+	for path := range prunable {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, item := range dirUsage(ctx, &diskUsage{seen: map[[2]uint64]bool{}}) {
+		want, ok := prunable[item.label]
+		if !ok {
+			continue
+		}
+		if got := item.remove != nil; got != want {
+			t.Errorf("%s prunable = %v, want %v", item.label, got, want)
+		}
+		delete(prunable, item.label)
+	}
+	if len(prunable) > 0 {
+		t.Errorf("not reported: %v", prunable)
+	}
+}
@@ -2,6 +2,8 @@ package commands
 
 import (
 	"fmt"
+
+	"github/llamarunner/utils"
 )
 
 // HelpCommand implements the Command interface for showing help
@@ -21,7 +23,7 @@ func NewHelpCommand() *HelpCommand {
 }
 
 // Run executes the help command
-func (c *HelpCommand) Run(args []string) {
+func (c *HelpCommand) Run(ctx *utils.Context, args []string) {
 	fmt.Println("Available commands:")
 
 	// Get all registered commands
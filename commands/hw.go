@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github/llamarunner/builder"
+	"github/llamarunner/hw"
+	"github/llamarunner/utils"
+)
+
+// HwCommand implements the Command interface for the hardware inventory
+type HwCommand struct {
+	*BaseCommand
+}
+
+// NewHwCommand creates a new hw command
+func NewHwCommand() *HwCommand {
+	return &HwCommand{
+		BaseCommand: NewBaseCommand(
+			"hw",
+			"Show the CPU, memory, NUMA nodes and GPUs of this machine",
+			"llamarunner hw [--root <dir>]\nOptions:\n  --root    Read /proc and /sys under this directory instead of /, without running vendor tools",
+		),
+	}
+}
+
+// Run executes the hw command
+func (c *HwCommand) Run(ctx *utils.Context, args []string) {
+	src := hw.SystemSource()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--root":
+			if i+1 >= len(args) {
+				fmt.Println("Missing value for --root")
+				return
+			}
+			src = hw.Source{Root: ctx.Dirs.ExpandPath(args[i+1])}
+			i++
+		default:
+			fmt.Printf("Unknown option: %s\n", args[i])
+			fmt.Println(c.Usage())
+			return
+		}
+	}
+
+	inv := hw.Detect(src)
+
+	cpu := inv.CPU
+	if cpu.Threads == 0 {
+		fmt.Println("CPU:       unknown")
+	} else {
+		fmt.Printf("CPU:       %s (%d socket(s), %d cores, %d threads)\n", cpu.Model, cpu.Sockets, cpu.Cores, cpu.Threads)
+		fmt.Printf("Features:  %s\n", strings.Join(cpu.Features(), " "))
+	}
+
+	if inv.Memory != nil {
+		fmt.Printf("Memory:    %s total, %s available\n", utils.FormatBytes(inv.Memory.Total), utils.FormatBytes(inv.Memory.Available))
+	}
+
+	if len(inv.NUMA) > 1 {
+		fmt.Printf("NUMA:      %d nodes\n", len(inv.NUMA))
+		for _, node := range inv.NUMA {
+			fmt.Printf("  node%d    CPUs %s, %s\n", node.ID, node.CPUs, utils.FormatBytes(node.MemTotal))
+		}
+	} else {
+		fmt.Println("NUMA:      single node")
+	}
+
+	if len(inv.GPUs) == 0 {
+		fmt.Println("GPUs:      none found")
+	} else {
+		fmt.Println("GPUs:")
+		for _, gpu := range inv.GPUs {
+			vram := ""
+			if gpu.VRAM > 0 {
+				vram = utils.FormatBytes(gpu.VRAM)
+			}
+			fmt.Printf("  %-7s %-40s %10s  (%s)\n", gpu.Vendor, gpu.Name, vram, gpu.Source)
+		}
+	}
+
+	// Toolchains are looked up on this machine even with --root
+	backend := builder.Detect(builder.SystemEnv(), inv.Vendors())
+	fmt.Printf("\nBuild backend: %s (%s)\n", backend.Name, backend.Description)
+	fmt.Printf("Preset defaults: threads=%d", defaultThreads(inv))
+	if hasDedicatedGPU(inv) {
+		fmt.Printf(" n_gpu_layers=99")
+	}
+	fmt.Println()
+}
+
+// defaultThreads is one thread per physical core, since hyperthreads slow
+// llama.cpp's generation down, falling back to 8 when unknown
+func defaultThreads(inv *hw.Inventory) int {
+	if inv.CPU.Cores > 0 {
+		return inv.CPU.Cores
+	}
+	return 8
+}
+
+// hasDedicatedGPU reports whether a GPU worth offloading layers to is
+// present. Intel devices are mostly integrated graphics sharing system RAM.
+func hasDedicatedGPU(inv *hw.Inventory) bool {
+	return inv.HasVendor("nvidia") || inv.HasVendor("amd")
+}
+
+// Register the hw command automatically
+func init() {
+	RegisterCommand("hw", NewHwCommand())
+}
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"github/llamarunner/hw"
 	"github/llamarunner/utils"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 // InitCommand implements the Command interface for initializing presets
@@ -24,7 +26,7 @@ func NewInitCommand() *InitCommand {
 }
 
 // Run executes the init command
-func (c *InitCommand) Run(args []string) {
+func (c *InitCommand) Run(ctx *utils.Context, args []string) {
 	fmt.Println("Initializing new preset...")
 
 	// Get preset name
@@ -42,12 +44,28 @@ func (c *InitCommand) Run(args []string) {
 	var modelPath string
 	fmt.Scanln(&modelPath)
 
+	// Defaults follow the hardware
+	inventory := hw.Detect(hw.SystemSource())
+	defaultThreadCount := strconv.Itoa(defaultThreads(inventory))
+
 	// Get threads
-	fmt.Print("Enter thread count (default 8): ")
+	fmt.Printf("Enter thread count (default %s): ", defaultThreadCount)
 	var threads string
 	fmt.Scanln(&threads)
 	if threads == "" {
-		threads = "8"
+		threads = defaultThreadCount
+	}
+
+	// Get GPU layers, offloading everything when there is a GPU
+	defaultGPULayers := "0"
+	if hasDedicatedGPU(inventory) {
+		defaultGPULayers = "99"
+	}
+	fmt.Printf("Enter GPU layers (default %s): ", defaultGPULayers)
+	var gpuLayers string
+	fmt.Scanln(&gpuLayers)
+	if gpuLayers == "" {
+		gpuLayers = defaultGPULayers
 	}
 
 	// Get n_predict
@@ -67,11 +85,14 @@ func (c *InitCommand) Run(args []string) {
 	}
 
 	// Create config file
-	configDir := utils.FindConfigDir()
+	configDir := utils.FindConfigDir(ctx)
 	configPath := filepath.Join(configDir, presetName+".cfg")
 
 	configContent := fmt.Sprintf("model=%s\nthreads=%s\nn_predict=%s\nctx_size=%s\n",
 		modelPath, threads, nPredict, ctxSize)
+	if gpuLayers != "0" {
+		configContent += fmt.Sprintf("n_gpu_layers=%s\n", gpuLayers)
+	}
 
 	err := os.WriteFile(configPath, []byte(configContent), 0644)
 	if err != nil {
@@ -3,10 +3,11 @@ package commands
 
 import (
 	"fmt"
+	"github/llamarunner/builder"
+	"github/llamarunner/hw"
 	"github/llamarunner/utils"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
@@ -21,25 +22,34 @@ func NewInstallCommand() *InstallCommand {
 		BaseCommand: NewBaseCommand(
 			"install",
 			"Downloads and builds llama.cpp with optimizations",
-			"llamarunner install",
+			"llamarunner install [-b|--build] [--skip-checks]\nOptions:\n  -b, --build    Build llama.cpp after cloning it\n  --skip-checks  Install without checking the build prerequisites first",
 		),
 	}
 }
 
 // Run executes the install command
-func (c *InstallCommand) Run(args []string) {
-	fmt.Println("Installing llama.cpp...")
-
-	// Check if git is available
-	if !isCommandAvailable("git") {
-		fmt.Println("Error: git is required but not found")
-		return
+func (c *InstallCommand) Run(ctx *utils.Context, args []string) {
+	buildFlag := false
+	skipChecks := false
+	for _, arg := range args {
+		switch arg {
+		case "-b", "--build":
+			buildFlag = true
+		case "--skip-checks":
+			skipChecks = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println(c.Usage())
+			return
+		}
 	}
 
+	fmt.Println("Installing llama.cpp...")
+
 	// Default installation directory
 	var installDir string
 	var input string
-	defaultDir := utils.FindLlamaCppDir()
+	defaultDir := utils.FindLlamaCppDir(ctx)
 	// Logic is buggy, TODO fix user prompt for folder
 	if defaultDir != "" {
 		installDir = defaultDir
@@ -54,6 +64,21 @@ func (c *InstallCommand) Run(args []string) {
 
 	fmt.Printf("Installing to: %s\n", installDir)
 
+	// Check everything the build needs before spending time on the clone
+	if !skipChecks {
+		inventory := hw.Detect(hw.SystemSource())
+		ok := runPreflight(builder.PreflightOptions{
+			Env:     builder.SystemEnv(),
+			Dir:     installDir,
+			Vendors: inventory.Vendors(),
+			Curl:    true,
+		})
+		if !ok {
+			fmt.Println("Error: missing prerequisites; install them, or pass --skip-checks to install anyway")
+			return
+		}
+	}
+
 	// Check if directory exists
 	if _, err := os.Stat(installDir); err == nil {
 		fmt.Printf("Directory %s already exists. Overwrite? (y/n): ", installDir)
@@ -76,51 +101,23 @@ func (c *InstallCommand) Run(args []string) {
 		return
 	}
 
-	// Check if build flag is present
-	buildFlag := false
-	for _, arg := range args {
-		if arg == "-b" || arg == "--build" {
-			buildFlag = true
-			break
-		}
-	}
-
 	// Build llama.cpp only if -b or --build flag is present
 	if buildFlag {
 		buildCmd := NewBuildCommand()
-		buildCmd.buildLlamaCpp(filepath.Join(installDir, "llama.cpp"))
+		buildCmd.buildLlamaCpp(ctx, installDir, BuildOptions{SkipChecks: skipChecks})
 	} else {
 		fmt.Println("Skipping build. Use -b or --build flag to build llama.cpp after installation.")
 	}
 
 	// Save the installation path to settings
-	settings, err := utils.LoadSettings()
-	if err != nil {
-		settings = &utils.Settings{}
-	}
-
-	settings.LlamaCppPath = installDir
-	err = utils.SaveSettings(settings)
+	ctx.Settings.LlamaCppPath = installDir
+	err = utils.SaveSettings(ctx)
 	if err != nil {
 		fmt.Printf("Error saving settings: %v\n", err)
 	} else {
 		fmt.Println("llama.cpp installed and configured successfully!")
 		fmt.Printf("Installation path: %s\n", installDir)
 	}
-	returnCmd := exec.Command("cd", "..")
-	returnCmd.Stderr = os.Stderr
-	returnCmd.Stdin = os.Stdin
-
-	retErr := returnCmd.Run()
-	if err != nil {
-		fmt.Printf("No idea how but we could not return to the parent folder -> %v", retErr)
-		return
-	}
-}
-
-func isCommandAvailable(cmd string) bool {
-	_, err := exec.LookPath(cmd)
-	return err == nil
 }
 
 // Register the install command automatically
@@ -25,9 +25,9 @@ func NewListCommand() *ListCommand {
 }
 
 // Run executes the list command
-func (c *ListCommand) Run(args []string) {
+func (c *ListCommand) Run(ctx *utils.Context, args []string) {
 	// Find the config directory using the existing utility function
-	configDir := utils.FindConfigDir()
+	configDir := utils.FindConfigDir(ctx)
 
 	// Read all files in the config directory
 	files, err := os.ReadDir(configDir)
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github/llamarunner/utils"
+)
+
+// modelSubcommand is a "llamarunner model <name>" subcommand
+type modelSubcommand struct {
+	description string
+	usage       string
+	run         func(ctx *utils.Context, args []string)
+}
+
+// modelSubcommands maps subcommand names to their implementations
+var modelSubcommands = map[string]modelSubcommand{}
+
+// registerModelSubcommand registers a subcommand of the model command
+func registerModelSubcommand(name, description, usage string, run func(ctx *utils.Context, args []string)) {
+	modelSubcommands[name] = modelSubcommand{
+		description: description,
+		usage:       usage,
+		run:         run,
+	}
+}
+
+// ModelCommand implements the Command interface for model management
+type ModelCommand struct {
+	*BaseCommand
+}
+
+// NewModelCommand creates a new model command
+func NewModelCommand() *ModelCommand {
+	return &ModelCommand{
+		BaseCommand: NewBaseCommand(
+			"model",
+			"Inspect and manage model files",
+			"llamarunner model <subcommand> [args]",
+		),
+	}
+}
+
+// Usage lists the registered subcommands and their usage
+func (c *ModelCommand) Usage() string {
+	names := make([]string, 0, len(modelSubcommands))
+	for name := range modelSubcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	builder.WriteString(c.BaseCommand.Usage())
+	builder.WriteString("\nSubcommands:")
+	for _, name := range names {
+		sub := modelSubcommands[name]
+		builder.WriteString(fmt.Sprintf("\n  %-10s %s\n             %s", name, sub.description, sub.usage))
+	}
+	return builder.String()
+}
+
+// Run executes the model command
+func (c *ModelCommand) Run(ctx *utils.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Println(c.Usage())
+		return
+	}
+
+	sub, exists := modelSubcommands[args[0]]
+	if !exists {
+		fmt.Printf("Unknown model subcommand: %s\n", args[0])
+		fmt.Println(c.Usage())
+		return
+	}
+
+	sub.run(ctx, args[1:])
+}
+
+// resolveModelArg returns the model file for an argument that is a path, a
+// store alias or the name of a preset
+func resolveModelArg(ctx *utils.Context, arg string) (string, error) {
+	path := ctx.Dirs.ExpandPath(arg)
+	if utils.FileExists(path) {
+		return path, nil
+	}
+	if model, ok := utils.ResolveAlias(ctx, arg); ok {
+		return model, nil
+	}
+
+	preset, err := utils.LoadPreset(ctx, arg)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a model file, alias or preset", arg)
+	}
+
+	model := preset.ModelPath(ctx)
+	if model == "" {
+		return "", fmt.Errorf("preset %s does not set a model", arg)
+	}
+	return model, nil
+}
+
+// Register the model command automatically
+func init() {
+	RegisterCommand("model", NewModelCommand())
+}
@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// convertScript is llama.cpp's Hugging Face to GGUF converter
+const convertScript = "convert_hf_to_gguf.py"
+
+// venvStamp records which requirements the venv was installed from
+const venvStamp = ".llamarunner-requirements"
+
+// convertOutTypes are the output types convert_hf_to_gguf.py supports
+var convertOutTypes = []string{"f32", "f16", "bf16", "q8_0", "tq1_0", "tq2_0", "auto"}
+
+// runModelConvert converts a Hugging Face checkpoint directory to GGUF
+func runModelConvert(ctx *utils.Context, args []string) {
+	var source, output, wheels string
+	outType := "f16"
+	preset, force := false, false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--outtype", "--output", "--wheels":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return
+			}
+			switch args[i] {
+			case "--outtype":
+				outType = strings.ToLower(args[i+1])
+			case "--output":
+				output = ctx.Dirs.ExpandPath(args[i+1])
+			case "--wheels":
+				wheels = ctx.Dirs.ExpandPath(args[i+1])
+			}
+			i++
+		case "--preset":
+			preset = true
+		case "--force":
+			force = true
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println("Usage: " + modelSubcommands["convert"].usage)
+				return
+			}
+			source = ctx.Dirs.ExpandPath(args[i])
+		}
+	}
+
+	if source == "" {
+		fmt.Println("Usage: " + modelSubcommands["convert"].usage)
+		return
+	}
+	if !isConvertOutType(outType) {
+		fmt.Printf("Error: unknown output type %s (expected one of %s)\n", outType, strings.Join(convertOutTypes, ", "))
+		return
+	}
+
+	source, err := filepath.Abs(source)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	err = checkCheckpointDir(source)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if output == "" {
+		modelDirs := utils.ModelDirs(ctx)
+		if len(modelDirs) == 0 {
+			fmt.Println("Error: model_path is not set; use --output")
+			return
+		}
+		name := filepath.Base(source)
+		if outType != "auto" {
+			name += "-" + strings.ToUpper(outType)
+		}
+		output = filepath.Join(modelDirs[0], name+".gguf")
+	}
+	if utils.FileExists(output) && !force {
+		fmt.Printf("Error: %s already exists (use --force to overwrite)\n", output)
+		return
+	}
+
+	llamaDir := ctx.Dirs.ExpandPath(utils.FindLlamaCppDir(ctx))
+	script := filepath.Join(llamaDir, convertScript)
+	if !utils.FileExists(script) {
+		fmt.Printf("Error: %s not found in %s; run 'llamarunner install' first\n", convertScript, llamaDir)
+		return
+	}
+
+	python, err := ensureConvertVenv(ctx, llamaDir, wheels)
+	if err != nil {
+		fmt.Printf("Error preparing the Python environment: %v\n", err)
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Write to a temporary name so a failed run never looks like a model
+	tmp := output + ".tmp"
+	convertArgs := []string{script, source, "--outfile", tmp, "--outtype", outType}
+	fmt.Printf("Converting %s to %s...\n", source, output)
+
+	err = runVerbose("", python, convertArgs...)
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Printf("Error converting %s: %v\n", source, err)
+		return
+	}
+
+	err = os.Rename(tmp, output)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Record the final name rather than the temporary one
+	convertArgs[3] = output
+	err = library.WriteProvenance(output, &library.Provenance{
+		Tool:           convertScript,
+		Source:         source,
+		Type:           strings.ToUpper(outType),
+		LlamaCppCommit: llamaCppCommit(ctx),
+		Command:        append([]string{python}, convertArgs...),
+		Created:        time.Now().UTC(),
+	})
+	if err != nil {
+		fmt.Printf("Error writing provenance: %v\n", err)
+		return
+	}
+
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+	entry, err := index.Add(output, false)
+	if err != nil {
+		fmt.Printf("Error indexing %s: %v\n", output, err)
+		return
+	}
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+		return
+	}
+
+	if entry.Error != "" {
+		fmt.Printf("Warning: the output could not be read as GGUF: %s\n", entry.Error)
+	}
+	fmt.Printf("Model written to %s\n", output)
+
+	if preset {
+		name, err := writeModelPreset(ctx, output, nil)
+		if err != nil {
+			fmt.Printf("Error creating preset: %v\n", err)
+		} else if name != "" {
+			fmt.Printf("Created preset %s\n", name)
+		}
+	}
+}
+
+// isConvertOutType reports whether outType is accepted by the converter
+func isConvertOutType(outType string) bool {
+	for _, known := range convertOutTypes {
+		if outType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCheckpointDir checks that dir looks like a Hugging Face checkpoint
+func checkCheckpointDir(dir string) error {
+	if !utils.FileExists(filepath.Join(dir, "config.json")) {
+		return fmt.Errorf("%s has no config.json; expected a Hugging Face model directory", dir)
+	}
+
+	for _, pattern := range []string{"*.safetensors", "pytorch_model*.bin"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no .safetensors or pytorch_model*.bin weights", dir)
+}
+
+// ensureConvertVenv creates the conversion venv if needed and installs the
+// converter's requirements whenever they change. Wheels are taken from the
+// wheels directory if given, offline, or else from the wheel cache if it
+// exists, falling back to the package index.
+func ensureConvertVenv(ctx *utils.Context, llamaDir, wheels string) (string, error) {
+	venv := ctx.Dirs.Venv()
+	python := filepath.Join(venv, "bin", "python")
+
+	requirements := filepath.Join(llamaDir, "requirements", "requirements-convert_hf_to_gguf.txt")
+	if !utils.FileExists(requirements) {
+		requirements = filepath.Join(llamaDir, "requirements.txt")
+	}
+	stamp, err := requirementsStamp(requirements)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %v", requirements, err)
+	}
+
+	if !utils.FileExists(python) {
+		fmt.Printf("Creating Python environment in %s...\n", venv)
+		err = runVerbose("", findPython(), "-m", "venv", venv)
+		if err != nil {
+			return "", fmt.Errorf("error creating venv: %v", err)
+		}
+	}
+
+	stampPath := filepath.Join(venv, venvStamp)
+	if current, err := os.ReadFile(stampPath); err == nil && strings.TrimSpace(string(current)) == stamp {
+		return python, nil
+	}
+
+	pipArgs := []string{"-m", "pip", "install", "-r", requirements}
+	switch {
+	case wheels != "":
+		pipArgs = append(pipArgs, "--no-index", "--find-links", wheels)
+	case utils.FileExists(ctx.Dirs.Wheels()):
+		pipArgs = append(pipArgs, "--find-links", ctx.Dirs.Wheels())
+	}
+
+	fmt.Println("Installing conversion requirements...")
+	err = runVerbose(llamaDir, python, pipArgs...)
+	if err != nil {
+		return "", fmt.Errorf("error installing requirements: %v", err)
+	}
+
+	err = os.WriteFile(stampPath, []byte(stamp+"\n"), 0644)
+	if err != nil {
+		return "", err
+	}
+	return python, nil
+}
+
+// requirementsStamp hashes a requirements file together with the files it
+// includes with "-r", so a change to any of them triggers a reinstall
+func requirementsStamp(path string) (string, error) {
+	hash := sha256.New()
+
+	seen := map[string]bool{}
+	pending := []string{path}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+
+		data, err := os.ReadFile(current)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && (fields[0] == "-r" || fields[0] == "--requirement") {
+				pending = append(pending, filepath.Join(filepath.Dir(current), fields[1]))
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// findPython returns the system Python used to create the venv
+func findPython() string {
+	for _, name := range []string{"python3", "python"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return "python3"
+}
+
+// runVerbose runs a command in dir with its output shown
+func runVerbose(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Register the model convert subcommand automatically
+func init() {
+	registerModelSubcommand("convert",
+		"Convert a Hugging Face checkpoint to GGUF",
+		"llamarunner model convert <hf-dir> [--outtype f16|bf16|f32|q8_0|auto] [--output <file>] [--wheels <dir>] [--preset] [--force]",
+		runModelConvert)
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// runModelIMatrix computes an importance matrix for a model with
+// llama-imatrix, reusing a cached one for the same model and dataset
+func runModelIMatrix(ctx *utils.Context, args []string) {
+	var target, calibration string
+	var extra []string
+	force := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--calibration", "--gpu-layers", "--chunks", "--threads":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return
+			}
+			name, value := args[i], args[i+1]
+			i++
+
+			if name == "--calibration" {
+				calibration = value
+				continue
+			}
+			if _, err := strconv.Atoi(value); err != nil {
+				fmt.Printf("Invalid value for %s: %s\n", name, value)
+				return
+			}
+			extra = append(extra, name, value)
+		case "--force":
+			force = true
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println("Usage: " + modelSubcommands["imatrix"].usage)
+				return
+			}
+			target = args[i]
+		}
+	}
+
+	if target == "" || calibration == "" {
+		fmt.Println("Usage: " + modelSubcommands["imatrix"].usage)
+		return
+	}
+
+	model, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	dataset, err := library.ResolveDataset(ctx, calibration)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+	fmt.Printf("Hashing %s...\n", model)
+	entry, err := index.Add(model, true)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", model, err)
+		return
+	}
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+		return
+	}
+
+	datasetHash, err := utils.HashFile(dataset)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", dataset, err)
+		return
+	}
+
+	output := library.IMatrixPath(ctx, entry.SHA256, datasetHash)
+	if utils.FileExists(output) && !force {
+		fmt.Printf("Using cached importance matrix %s (use --force to recompute)\n", output)
+		return
+	}
+
+	binary, err := ensureLlamaBinary(ctx, "llama-imatrix")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(output), 0755)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	tmp := library.IMatrixTempPath(output)
+	imatrixArgs := append([]string{"-m", model, "-f", dataset, "-o", tmp}, extra...)
+
+	fmt.Printf("Computing importance matrix for %s on %s...\n", filepath.Base(model), filepath.Base(dataset))
+	err = runVerbose("", binary, imatrixArgs...)
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Printf("Error computing importance matrix: %v\n", err)
+		return
+	}
+
+	err = os.Rename(tmp, output)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Record the final name rather than the temporary one
+	imatrixArgs[5] = output
+	err = library.WriteProvenance(output, &library.Provenance{
+		Tool:           "llama-imatrix",
+		Source:         model,
+		SourceSHA256:   entry.SHA256,
+		Dataset:        dataset,
+		DatasetSHA256:  datasetHash,
+		LlamaCppCommit: llamaCppCommit(ctx),
+		Command:        append([]string{binary}, imatrixArgs...),
+		Created:        time.Now().UTC(),
+	})
+	if err != nil {
+		fmt.Printf("Error writing provenance: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Importance matrix written to %s\n", output)
+	fmt.Println("model quantize will use it automatically for low-bit types")
+}
+
+// runModelDataset manages the named calibration datasets
+func runModelDataset(ctx *utils.Context, args []string) {
+	usage := "Usage: " + modelSubcommands["dataset"].usage
+
+	if len(args) == 0 || args[0] == "list" {
+		names, err := library.ListDatasets(ctx)
+		if err != nil {
+			fmt.Printf("Error listing datasets: %v\n", err)
+			return
+		}
+		if len(names) == 0 {
+			fmt.Println("No calibration datasets. Add one with 'llamarunner model dataset add <name> <file>'")
+			return
+		}
+		for _, name := range names {
+			size := ""
+			if info, err := os.Stat(library.DatasetPath(ctx, name)); err == nil {
+				size = utils.FormatBytes(info.Size())
+			}
+			fmt.Printf("%-30s %10s\n", name, size)
+		}
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			fmt.Println(usage)
+			return
+		}
+		err := library.AddDataset(ctx, args[1], ctx.Dirs.ExpandPath(args[2]))
+		if err != nil {
+			fmt.Printf("Error adding dataset: %v\n", err)
+			return
+		}
+		fmt.Printf("Added dataset %s\n", args[1])
+	case "rm":
+		if len(args) != 2 {
+			fmt.Println(usage)
+			return
+		}
+		err := library.RemoveDataset(ctx, args[1])
+		if err != nil {
+			fmt.Printf("Error removing dataset: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed dataset %s\n", args[1])
+	default:
+		fmt.Println(usage)
+	}
+}
+
+// Register the model imatrix and dataset subcommands automatically
+func init() {
+	registerModelSubcommand("imatrix",
+		"Compute an importance matrix for low-bit quantization",
+		"llamarunner model imatrix <file|preset> --calibration <dataset|file> [--gpu-layers <n>] [--chunks <n>] [--threads <n>] [--force]",
+		runModelIMatrix)
+	registerModelSubcommand("dataset",
+		"Manage calibration datasets for model imatrix",
+		"llamarunner model dataset [list | add <name> <file> | rm <name>]",
+		runModelDataset)
+}
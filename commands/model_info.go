@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/utils"
+)
+
+// runModelInfo prints a summary of a GGUF model's metadata and tensors
+func runModelInfo(ctx *utils.Context, args []string) {
+	var target string
+	showMetadata := false
+	showTemplate := true
+
+	for _, arg := range args {
+		switch arg {
+		case "--metadata":
+			showMetadata = true
+		case "--no-template":
+			showTemplate = false
+		default:
+			target = arg
+		}
+	}
+
+	if target == "" {
+		fmt.Println("Usage: " + modelSubcommands["info"].usage)
+		return
+	}
+
+	path, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	model, err := gguf.OpenModel(path)
+	if err != nil {
+		fmt.Printf("Error reading model: %v\n", err)
+		return
+	}
+	meta := model.Metadata()
+
+	fmt.Printf("File:            %s\n", path)
+	if len(model.Shards) > 1 {
+		fmt.Printf("Shards:          %d\n", len(model.Shards))
+	}
+	fmt.Printf("Size:            %s\n", utils.FormatBytes(model.FileSize()))
+	fmt.Printf("GGUF version:    %d\n", meta.Version)
+	if name := meta.GetString("general.name"); name != "" {
+		fmt.Printf("Name:            %s\n", name)
+	}
+	fmt.Printf("Architecture:    %s\n", meta.Architecture())
+	fmt.Printf("Parameters:      %s\n", utils.FormatCount(model.ParameterCount()))
+	if fileType := meta.FileType(); fileType != "" {
+		fmt.Printf("Quantization:    %s\n", fileType)
+	}
+	printArchUint(meta, "Context length:", "context_length")
+	printArchUint(meta, "Embedding size:", "embedding_length")
+	printArchUint(meta, "Layers:", "block_count")
+	printArchUint(meta, "Attention heads:", "attention.head_count")
+	printArchUint(meta, "KV heads:", "attention.head_count_kv")
+	printArchUint(meta, "Experts:", "expert_count")
+
+	if tokenizer := meta.GetString("tokenizer.ggml.model"); tokenizer != "" {
+		if tokens, ok := meta.GetArray("tokenizer.ggml.tokens"); ok {
+			fmt.Printf("Tokenizer:       %s (vocabulary %d)\n", tokenizer, len(tokens.Values))
+		} else {
+			fmt.Printf("Tokenizer:       %s\n", tokenizer)
+		}
+	}
+
+	fmt.Printf("\nTensors (%d):\n", len(model.Tensors()))
+	fmt.Printf("  %-28s %6s %10s %10s  %s\n", "GROUP", "COUNT", "PARAMS", "SIZE", "TYPES")
+	for _, group := range gguf.GroupTensors(model.Tensors()) {
+		fmt.Printf("  %-28s %6d %10s %10s  %s\n",
+			group.Name, group.Count, utils.FormatCount(group.Elements),
+			utils.FormatBytes(int64(group.Size)), group.TypeSummary())
+	}
+
+	if showMetadata {
+		fmt.Printf("\nMetadata (%d keys):\n", len(meta.Metadata))
+		for _, kv := range meta.Metadata {
+			fmt.Printf("  %-40s %-8s %s\n", kv.Key, kv.Type, gguf.FormatValue(kv.Value, 80))
+		}
+	}
+
+	if showTemplate {
+		template := meta.ChatTemplate()
+		if template == "" {
+			fmt.Println("\nChat template:   (none)")
+		} else {
+			fmt.Println("\nChat template:")
+			for _, line := range strings.Split(template, "\n") {
+				fmt.Println("  " + line)
+			}
+		}
+	}
+}
+
+// printArchUint prints an architecture-specific integer if present
+func printArchUint(meta *gguf.File, label, suffix string) {
+	if value, ok := meta.ArchUint(suffix); ok {
+		fmt.Printf("%-16s %d\n", label, value)
+	}
+}
+
+// Register the model info subcommand automatically
+func init() {
+	registerModelSubcommand("info",
+		"Show architecture, size, quantization and chat template of a model",
+		"llamarunner model info <file|preset> [--metadata] [--no-template]",
+		runModelInfo)
+}
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// runModelList scans the model directories and prints the library
+func runModelList(ctx *utils.Context, args []string) {
+	var opts library.ScanOptions
+	orphansOnly := false
+
+	for _, arg := range args {
+		switch arg {
+		case "--rescan":
+			opts.Rescan = true
+		case "--no-hash":
+			opts.NoHash = true
+		case "--orphans":
+			orphansOnly = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println("Usage: " + modelSubcommands["list"].usage)
+			return
+		}
+	}
+
+	index, err := library.Scan(ctx, opts)
+	if err != nil {
+		fmt.Printf("Error scanning models: %v\n", err)
+		return
+	}
+
+	refs, missing, err := library.PresetReferences(ctx)
+	if err != nil {
+		fmt.Printf("Error reading presets: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Model directories: %s\n\n", strings.Join(utils.ModelDirs(ctx), ", "))
+
+	var total int64
+	shown, orphans := 0, 0
+	fmt.Printf("%-50s %10s %-10s %-9s %8s  %s\n", "MODEL", "SIZE", "ARCH", "QUANT", "CONTEXT", "PRESETS")
+	for _, entry := range index.Entries {
+		presets := refs[entry.Path]
+		if len(presets) == 0 {
+			orphans++
+		}
+		if orphansOnly && len(presets) > 0 {
+			continue
+		}
+
+		name := displayModelPath(ctx, entry.Path)
+		if shard, ok := gguf.ParseShardName(entry.Path); ok {
+			name = fmt.Sprintf("%s (%d/%d)", name, shard.Index, shard.Count)
+		}
+
+		used := strings.Join(presets, ", ")
+		if used == "" {
+			used = "(orphaned)"
+		}
+		if entry.Error != "" {
+			used += " [unreadable: " + entry.Error + "]"
+		}
+
+		context := ""
+		if entry.ContextLength > 0 {
+			context = fmt.Sprintf("%d", entry.ContextLength)
+		}
+
+		fmt.Printf("%-50s %10s %-10s %-9s %8s  %s\n", name, utils.FormatBytes(entry.Size),
+			entry.Architecture, entry.Quantization, context, used)
+		total += entry.Size
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Println("  No models found")
+	}
+	fmt.Printf("\nTotal: %d model file(s), %s, %d orphaned\n", shown, utils.FormatBytes(total), orphans)
+
+	if len(missing) > 0 {
+		fmt.Println("\nPresets pointing at missing models:")
+		for _, m := range missing {
+			fmt.Printf("  %-20s %s\n", m.Preset, m.Model)
+		}
+	}
+}
+
+// displayModelPath shortens a model path relative to its model directory
+func displayModelPath(ctx *utils.Context, path string) string {
+	for _, dir := range utils.ModelDirs(ctx) {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// Register the model list subcommand automatically
+func init() {
+	registerModelSubcommand("list",
+		"Scan model directories and list models, their presets and orphans",
+		"llamarunner model list [--rescan] [--no-hash] [--orphans]",
+		runModelList)
+}
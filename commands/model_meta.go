@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// metaOptions are the options shared by the meta actions
+type metaOptions struct {
+	output    string
+	valueType string
+	noBackup  bool
+}
+
+// runModelMeta edits GGUF metadata, writing a new file or rewriting the
+// model in place with a backup
+func runModelMeta(ctx *utils.Context, args []string) {
+	usage := "Usage: " + modelSubcommands["meta"].usage
+
+	var opts metaOptions
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--output", "--type":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return
+			}
+			if args[i] == "--output" {
+				opts.output = ctx.Dirs.ExpandPath(args[i+1])
+			} else {
+				opts.valueType = args[i+1]
+			}
+			i++
+		case "--no-backup":
+			opts.noBackup = true
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+
+	if len(positional) < 3 {
+		fmt.Println(usage)
+		return
+	}
+	action, target := positional[0], positional[1]
+
+	path, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	// Store files are shared by every alias with the same contents, and
+	// rewriting through the alias link would replace the link with a file
+	if opts.output == "" && library.IsBlob(ctx, path) {
+		fmt.Printf("Error: %s is in the model store; write the edited model to a new file with --output\n", target)
+		return
+	}
+	if shard, ok := gguf.ParseShardName(path); ok && shard.Index != 1 {
+		fmt.Printf("Error: metadata is stored in the first shard, %s\n", gguf.ShardPath(shard.Prefix, 1, shard.Count))
+		return
+	}
+
+	file, err := gguf.Open(path)
+	if err != nil {
+		fmt.Printf("Error reading model: %v\n", err)
+		return
+	}
+	if file.Truncated() {
+		fmt.Printf("Error: %s is truncated; run 'llamarunner model verify' for details\n", path)
+		return
+	}
+
+	switch {
+	case action == "set" && len(positional) == 4:
+		err = metaSet(file, positional[2], positional[3], opts.valueType)
+	case action == "rm" && len(positional) == 3:
+		err = file.Remove(positional[2])
+		if err == nil {
+			fmt.Printf("Removed %s\n", positional[2])
+		}
+	case action == "set-template" && len(positional) == 3:
+		var template []byte
+		template, err = os.ReadFile(ctx.Dirs.ExpandPath(positional[2]))
+		if err == nil {
+			err = file.Set("tokenizer.chat_template", gguf.TypeString, string(template))
+		}
+		if err == nil {
+			fmt.Printf("Set tokenizer.chat_template from %s (%d bytes)\n", positional[2], len(template))
+		}
+	default:
+		fmt.Println(usage)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	err = writeMetadata(ctx, file, opts)
+	if err != nil {
+		fmt.Printf("Error writing model: %v\n", err)
+	}
+}
+
+// metaSet parses value and sets key, keeping the type of an existing key
+// unless typeName is given
+func metaSet(file *gguf.File, key, text, typeName string) error {
+	var valueType gguf.ValueType
+	switch {
+	case typeName != "":
+		t, ok := gguf.ParseValueType(typeName)
+		if !ok {
+			return fmt.Errorf("unknown type %s", typeName)
+		}
+		valueType = t
+	default:
+		valueType = gguf.InferValueType(text)
+		for _, kv := range file.Metadata {
+			if kv.Key == key {
+				valueType = kv.Type
+			}
+		}
+	}
+	if valueType == gguf.TypeArray {
+		return fmt.Errorf("%s is an array; arrays can't be edited", key)
+	}
+
+	value, err := gguf.ParseValue(valueType, text)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q for %s", valueType, text, key)
+	}
+
+	err = file.Set(key, valueType, value)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Set %s = %s (%s)\n", key, gguf.FormatValue(value, 60), valueType)
+	return nil
+}
+
+// writeMetadata writes the edited file to opts.output, or over the original
+// after keeping a backup, and checks the result
+func writeMetadata(ctx *utils.Context, file *gguf.File, opts metaOptions) error {
+	dest := opts.output
+	if dest == "" {
+		dest = file.Path
+		if !opts.noBackup {
+			err := backupModel(file.Path)
+			if err != nil {
+				return fmt.Errorf("error creating backup: %v", err)
+			}
+		}
+	} else if utils.FileExists(dest) {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	err := file.Rewrite(dest)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the result reads back with the same tensors
+	written, err := gguf.Open(dest)
+	if err != nil {
+		return fmt.Errorf("the rewritten file can't be read: %v", err)
+	}
+	if len(written.Tensors) != len(file.Tensors) || len(written.Check()) > 0 {
+		return fmt.Errorf("the rewritten file %s failed verification", dest)
+	}
+
+	index, err := library.LoadIndex(ctx)
+	if err == nil {
+		_, err = index.Add(dest, false)
+	}
+	if err == nil {
+		err = index.Save()
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not update the model index: %v\n", err)
+	}
+
+	fmt.Printf("Wrote %s\n", dest)
+	return nil
+}
+
+// backupModel keeps the original of a model about to be rewritten in place.
+// An existing backup is left alone, since it holds the oldest original.
+func backupModel(path string) error {
+	backup := path + ".bak"
+	if utils.FileExists(backup) {
+		fmt.Printf("Keeping existing backup %s\n", backup)
+		return nil
+	}
+
+	// A hard link is instant and the rewrite replaces path with a new file,
+	// so the link keeps the original contents
+	err := os.Link(path, backup)
+	if err != nil {
+		err = utils.CopyFile(path, backup, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup saved to %s\n", backup)
+	return nil
+}
+
+// Register the model meta subcommand automatically
+func init() {
+	registerModelSubcommand("meta",
+		"Edit GGUF metadata such as the chat template",
+		"llamarunner model meta set <file|preset> <key> <value> [--type <type>] | rm <file|preset> <key> | set-template <file|preset> <jinja-file> [--output <file>] [--no-backup]",
+		runModelMeta)
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// writeTinyModel writes a GGUF file with only general.architecture set
+func writeTinyModel(t *testing.T, path string) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("GGUF")
+	for _, field := range []interface{}{uint32(3), uint64(0), uint64(1), uint64(len("general.architecture"))} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("general.architecture")
+	binary.Write(&buf, binary.LittleEndian, uint32(gguf.TypeString))
+	binary.Write(&buf, binary.LittleEndian, uint64(len("llama")))
+	buf.WriteString("llama")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// modelName returns general.name of the model at path
+func modelName(t *testing.T, path string) string {
+	t.Helper()
+	file, err := gguf.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.GetString("general.name")
+}
+
+func TestModelMetaAlias(t *testing.T) {
+	ctx := duContext(t)
+	model := filepath.Join(ctx.Dirs.Models(), "tiny.gguf")
+	writeTinyModel(t, model)
+	alias, err := library.AddAlias(ctx, "tiny", model, library.StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// In place edits of the alias, or of its blob, are refused
+	for _, target := range []string{"tiny", alias.Files[0], alias.Blobs[0]} {
+		runModelMeta(ctx, []string{"set", target, "general.name", "edited"})
+	}
+	if info, err := os.Lstat(alias.Files[0]); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("alias file is no longer a link: %v, %v", info, err)
+	}
+	if name := modelName(t, alias.Files[0]); name != "" {
+		t.Errorf("alias general.name = %q after a refused edit", name)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(utils.AliasDir(ctx, "tiny"), "*.bak")); len(matches) > 0 {
+		t.Errorf("backups created in the store: %q", matches)
+	}
+
+	// Writing to a new file works
+	output := filepath.Join(t.TempDir(), "edited.gguf")
+	runModelMeta(ctx, []string{"set", "tiny", "general.name", "edited", "--output", output})
+	if name := modelName(t, output); name != "edited" {
+		t.Errorf("output general.name = %q, want edited", name)
+	}
+	if name := modelName(t, alias.Files[0]); name != "" {
+		t.Errorf("alias general.name = %q after writing elsewhere", name)
+	}
+
+	// Files outside the store are still edited in place with a backup
+	runModelMeta(ctx, []string{"set", model, "general.name", "edited"})
+	if name := modelName(t, model); name != "edited" {
+		t.Errorf("model general.name = %q, want edited", name)
+	}
+	if !utils.FileExists(model + ".bak") {
+		t.Error("no backup of the edited model")
+	}
+}
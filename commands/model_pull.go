@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github/llamarunner/hub"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// defaultPullConnections is the number of parallel requests for large files
+const defaultPullConnections = 4
+
+// runModelPull downloads a model from the configured hub into the first
+// model directory
+func runModelPull(ctx *utils.Context, args []string) {
+	var ref string
+	revision := hub.DefaultRevision
+	connections := defaultPullConnections
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--revision", "--connections":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return
+			}
+			if args[i] == "--revision" {
+				revision = args[i+1]
+			} else {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fmt.Printf("Invalid connection count: %s\n", args[i+1])
+					return
+				}
+				connections = n
+			}
+			i++
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println("Usage: " + modelSubcommands["pull"].usage)
+				return
+			}
+			ref = args[i]
+		}
+	}
+
+	if ref == "" {
+		fmt.Println("Usage: " + modelSubcommands["pull"].usage)
+		return
+	}
+
+	repo, selector, err := hub.ParseRef(ref)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	modelDirs := utils.ModelDirs(ctx)
+	if len(modelDirs) == 0 {
+		fmt.Println("Error: model_path is not set")
+		return
+	}
+
+	client := hub.NewClient(ctx)
+	fmt.Printf("Resolving %s from %s...\n", repo, client.BaseURL)
+
+	files, err := client.ListFiles(repo, revision)
+	if err != nil {
+		fmt.Printf("Error listing %s: %v\n", repo, err)
+		return
+	}
+
+	selected, err := hub.Resolve(files, selector)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+
+	// The hub names the files, so check every path before downloading any
+	dests := make([]string, len(selected))
+	for i, file := range selected {
+		dests[i], err = pullDestination(modelDirs[0], repo, file.Path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	var first string
+	for i, file := range selected {
+		dest := dests[i]
+		if i == 0 {
+			first = dest
+		}
+
+		if len(selected) > 1 {
+			fmt.Printf("[%d/%d] ", i+1, len(selected))
+		}
+		fmt.Printf("%s (%s)\n", file.Path, utils.FormatBytes(file.Size))
+
+		if isPulled(index, dest, file) {
+			fmt.Println("Already downloaded")
+			continue
+		}
+
+		err = client.Download(repo, revision, file, dest, connections)
+		if err != nil {
+			fmt.Printf("Error downloading %s: %v\n", file.Path, err)
+			return
+		}
+
+		// The download was verified against the hub's hash, so don't rehash
+		entry, err := index.Add(dest, false)
+		if err != nil {
+			fmt.Printf("Error indexing %s: %v\n", dest, err)
+			return
+		}
+		entry.SHA256 = file.SHA256
+	}
+
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\nModel saved to %s\n", first)
+	fmt.Printf("Use it in a preset with: model=%s\n", first)
+}
+
+// pullDestination returns where file of repo is saved under dir, rejecting
+// names that would escape it
+func pullDestination(dir, repo, file string) (string, error) {
+	repoPath := filepath.FromSlash(repo)
+	filePath := filepath.FromSlash(file)
+	if !filepath.IsLocal(repoPath) || filepath.Clean(repoPath) != repoPath {
+		return "", fmt.Errorf("invalid repository name %q", repo)
+	}
+	if !filepath.IsLocal(filePath) {
+		return "", fmt.Errorf("invalid file name %q in %s", file, repo)
+	}
+
+	repoDir := filepath.Join(dir, repoPath)
+	dest := filepath.Join(repoDir, filePath)
+	rel, err := filepath.Rel(repoDir, dest)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("file %q of %s resolves outside %s", file, repo, repoDir)
+	}
+	return dest, nil
+}
+
+// isPulled reports whether dest already holds file, trusting the index's
+// hash when the file is unchanged since it was indexed
+func isPulled(index *library.Index, dest string, file hub.File) bool {
+	info, err := os.Stat(dest)
+	if err != nil || info.Size() != file.Size {
+		return false
+	}
+	if file.SHA256 == "" {
+		return true
+	}
+
+	entry, err := index.Add(dest, false)
+	if err != nil {
+		return false
+	}
+	if entry.SHA256 == "" {
+		fmt.Println("Verifying existing file...")
+		entry.SHA256, err = utils.HashFile(dest)
+		if err != nil {
+			return false
+		}
+	}
+	return strings.EqualFold(entry.SHA256, file.SHA256)
+}
+
+// Register the model pull subcommand automatically
+func init() {
+	registerModelSubcommand("pull",
+		"Download a model from the configured hub",
+		"llamarunner model pull <owner/repo>[:<file|quant>] [--revision <rev>] [--connections <n>]",
+		runModelPull)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPullDestination(t *testing.T) {
+	dir := filepath.Join("models", "hub")
+	tests := []struct {
+		repo, file string
+		want       string
+	}{
+		{"owner/name", "model-Q4_K_M.gguf", filepath.Join(dir, "owner", "name", "model-Q4_K_M.gguf")},
+		{"owner/name", "Q4_K_M/model-00001-of-00002.gguf", filepath.Join(dir, "owner", "name", "Q4_K_M", "model-00001-of-00002.gguf")},
+		{"owner/name", "sub/../model.gguf", filepath.Join(dir, "owner", "name", "model.gguf")},
+		{"../name", "model.gguf", ""},
+		{"owner/..", "model.gguf", ""},
+		{"/owner/name", "model.gguf", ""},
+		{"owner/name", "../../../.bashrc", ""},
+		{"owner/name", "sub/../../../model.gguf", ""},
+		{"owner/name", "/etc/passwd", ""},
+		{"owner/name", "", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := pullDestination(dir, tt.repo, tt.file)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("pullDestination(%q, %q) = %q, want an error", tt.repo, tt.file, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("pullDestination(%q, %q) = %q, %v; want %q", tt.repo, tt.file, got, err, tt.want)
+		}
+	}
+}
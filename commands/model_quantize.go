@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// quantizeOptions are the parsed arguments of "model quantize"
+type quantizeOptions struct {
+	input     string
+	types     []string
+	imatrix   string
+	outputDir string
+	threads   int
+	preset    bool
+	force     bool
+
+	// noIMatrix disables picking up a cached importance matrix
+	noIMatrix bool
+}
+
+// runModelQuantize produces one or more quantizations of a model with
+// llama-quantize
+func runModelQuantize(ctx *utils.Context, args []string) {
+	opts, err := parseQuantizeArgs(args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Usage: " + modelSubcommands["quantize"].usage)
+		return
+	}
+
+	input, err := resolveModelArg(ctx, opts.input)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// A preset argument lends its options to the generated presets
+	var sourcePreset *utils.Preset
+	if !utils.FileExists(ctx.Dirs.ExpandPath(opts.input)) {
+		sourcePreset, _ = utils.LoadPreset(ctx, opts.input)
+	}
+
+	outputDir := filepath.Dir(input)
+	if opts.outputDir != "" {
+		outputDir = ctx.Dirs.ExpandPath(opts.outputDir)
+	}
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		fmt.Printf("Error creating %s: %v\n", outputDir, err)
+		return
+	}
+
+	binary, err := ensureLlamaBinary(ctx, "llama-quantize")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Hashing %s...\n", input)
+	source, err := index.Add(input, true)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", input, err)
+		return
+	}
+
+	provenance := library.Provenance{
+		Tool:           "llama-quantize",
+		Source:         input,
+		SourceSHA256:   source.SHA256,
+		LlamaCppCommit: llamaCppCommit(ctx),
+	}
+
+	// An explicit --imatrix applies to every type. Otherwise the latest
+	// cached matrix computed for this model is used for low-bit types.
+	var imatrix, imatrixHash string
+	if opts.imatrix != "" {
+		imatrix = ctx.Dirs.ExpandPath(opts.imatrix)
+	} else if !opts.noIMatrix && anyNeedsIMatrix(opts.types) {
+		imatrix, err = library.FindIMatrix(ctx, source.SHA256)
+		if err != nil {
+			fmt.Printf("Error looking up importance matrices: %v\n", err)
+			return
+		}
+		if imatrix != "" {
+			fmt.Printf("Using importance matrix %s for low-bit types\n", imatrix)
+		}
+	}
+	if imatrix != "" {
+		imatrixHash, err = utils.HashFile(imatrix)
+		if err != nil {
+			fmt.Printf("Error reading importance matrix: %v\n", err)
+			return
+		}
+	}
+
+	var outputs []string
+	for i, qtype := range opts.types {
+		output := filepath.Join(outputDir, quantizedName(input, qtype))
+		fmt.Printf("\n[%d/%d] %s -> %s\n", i+1, len(opts.types), qtype, output)
+
+		if utils.FileExists(output) && !opts.force {
+			fmt.Println("Output already exists, skipping (use --force to overwrite)")
+			continue
+		}
+
+		typeIMatrix := imatrix
+		if opts.imatrix == "" && !library.NeedsIMatrix(qtype) {
+			typeIMatrix = ""
+		}
+		if typeIMatrix == "" && library.NeedsIMatrix(qtype) {
+			fmt.Printf("Warning: %s needs an importance matrix for good quality; create one with 'llamarunner model imatrix'\n", qtype)
+		}
+
+		// Write to a temporary name so a failed run never looks like a model
+		tmp := output + ".tmp"
+		cmd := exec.Command(binary, quantizeArgs(typeIMatrix, input, tmp, qtype, opts.threads)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err != nil {
+			os.Remove(tmp)
+			fmt.Printf("Error quantizing to %s: %v\n", qtype, err)
+			return
+		}
+
+		err = os.Rename(tmp, output)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		record := provenance
+		record.Type = qtype
+		if typeIMatrix != "" {
+			record.IMatrix = typeIMatrix
+			record.IMatrixSHA256 = imatrixHash
+		}
+		record.Command = append([]string{binary}, quantizeArgs(typeIMatrix, input, output, qtype, opts.threads)...)
+		record.Created = time.Now().UTC()
+		err = library.WriteProvenance(output, &record)
+		if err != nil {
+			fmt.Printf("Error writing provenance: %v\n", err)
+			return
+		}
+
+		_, err = index.Add(output, false)
+		if err != nil {
+			fmt.Printf("Error indexing %s: %v\n", output, err)
+			return
+		}
+		outputs = append(outputs, output)
+
+		if opts.preset {
+			name, err := writeModelPreset(ctx, output, sourcePreset)
+			if err != nil {
+				fmt.Printf("Error creating preset: %v\n", err)
+			} else if name != "" {
+				fmt.Printf("Created preset %s\n", name)
+			}
+		}
+	}
+
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\nCreated %d quantization(s)\n", len(outputs))
+}
+
+// parseQuantizeArgs parses the input, types and options of "model quantize"
+func parseQuantizeArgs(args []string) (*quantizeOptions, error) {
+	opts := &quantizeOptions{}
+	seen := map[string]bool{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--imatrix", "--output-dir", "--threads":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			switch arg {
+			case "--imatrix":
+				opts.imatrix = args[i]
+			case "--output-dir":
+				opts.outputDir = args[i]
+			case "--threads":
+				n, err := strconv.Atoi(args[i])
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("invalid thread count: %s", args[i])
+				}
+				opts.threads = n
+			}
+		case "--preset":
+			opts.preset = true
+		case "--force":
+			opts.force = true
+		case "--no-imatrix":
+			opts.noIMatrix = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			if opts.input == "" {
+				opts.input = arg
+				continue
+			}
+
+			qtype, ok := gguf.ParseFileTypeName(arg)
+			if !ok {
+				return nil, fmt.Errorf("unknown quantization type: %s", arg)
+			}
+			if !seen[qtype] {
+				seen[qtype] = true
+				opts.types = append(opts.types, qtype)
+			}
+		}
+	}
+
+	if opts.input == "" || len(opts.types) == 0 {
+		return nil, fmt.Errorf("an input model and at least one type are required")
+	}
+	return opts, nil
+}
+
+// anyNeedsIMatrix reports whether any of types is a low-bit type
+func anyNeedsIMatrix(types []string) bool {
+	for _, qtype := range types {
+		if library.NeedsIMatrix(qtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// quantizedName names the output of quantizing input to qtype by replacing
+// the input's type suffix, so "Llama-3-8B-F16.gguf" becomes
+// "Llama-3-8B-Q4_K_M.gguf". Split inputs lose their shard suffix.
+func quantizedName(input, qtype string) string {
+	stem := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	if shard, ok := gguf.ParseShardName(input); ok {
+		stem = filepath.Base(shard.Prefix)
+	}
+
+	if i := strings.LastIndexAny(stem, "-."); i >= 0 {
+		if _, ok := gguf.ParseFileTypeName(stem[i+1:]); ok {
+			stem = stem[:i]
+		}
+	}
+
+	return stem + "-" + qtype + ".gguf"
+}
+
+// quantizeArgs returns the llama-quantize arguments for one output
+func quantizeArgs(imatrix, input, output, qtype string, threads int) []string {
+	var args []string
+	if imatrix != "" {
+		args = append(args, "--imatrix", imatrix)
+	}
+	args = append(args, input, output, qtype)
+	if threads > 0 {
+		args = append(args, strconv.Itoa(threads))
+	}
+	return args
+}
+
+// writeModelPreset creates a preset named after model, copying the options
+// of base if given. It returns "" without error if the preset already exists.
+func writeModelPreset(ctx *utils.Context, model string, base *utils.Preset) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(model), ".gguf")
+	path := utils.PresetPath(ctx, name)
+	if utils.FileExists(path) {
+		fmt.Printf("Preset %s already exists, leaving it unchanged\n", name)
+		return "", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("model=" + model + "\n")
+	if base != nil {
+		for _, option := range base.Options {
+			if option.Key != "model" {
+				builder.WriteString(option.Key + "=" + option.Value + "\n")
+			}
+		}
+	}
+
+	err := os.WriteFile(path, []byte(builder.String()), 0644)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Register the model quantize subcommand automatically
+func init() {
+	registerModelSubcommand("quantize",
+		"Quantize a model to one or more types with llama-quantize",
+		"llamarunner model quantize <file|preset> <type...> [--imatrix <file>] [--output-dir <dir>] [--no-imatrix] [--threads <n>] [--preset] [--force]",
+		runModelQuantize)
+}
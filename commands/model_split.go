@@ -0,0 +1,304 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// maxSizePattern matches llama-gguf-split sizes such as "4G" or "500M"
+var maxSizePattern = regexp.MustCompile(`^[0-9]+[MG]$`)
+
+// runModelSplit splits a model into shards with llama-gguf-split
+func runModelSplit(ctx *utils.Context, args []string) {
+	var target, maxSize, outputDir string
+	var maxTensors int
+	removeOriginal := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--max-size", "--max-tensors", "--output-dir":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return
+			}
+			switch args[i] {
+			case "--max-size":
+				maxSize = strings.ToUpper(args[i+1])
+			case "--max-tensors":
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n <= 0 {
+					fmt.Printf("Error: invalid tensor count %s, expected a positive number\n", args[i+1])
+					fmt.Println("Usage: " + modelSubcommands["split"].usage)
+					return
+				}
+				maxTensors = n
+			case "--output-dir":
+				outputDir = ctx.Dirs.ExpandPath(args[i+1])
+			}
+			i++
+		case "--remove-original":
+			removeOriginal = true
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println("Usage: " + modelSubcommands["split"].usage)
+				return
+			}
+			target = args[i]
+		}
+	}
+
+	if target == "" || (maxSize == "") == (maxTensors == 0) {
+		fmt.Println("Usage: " + modelSubcommands["split"].usage)
+		return
+	}
+	if maxSize != "" && !maxSizePattern.MatchString(maxSize) {
+		fmt.Printf("Error: invalid size %s, expected a number followed by M or G\n", maxSize)
+		return
+	}
+
+	input, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if _, ok := gguf.ParseShardName(input); ok {
+		fmt.Printf("Error: %s is already split; merge it first\n", input)
+		return
+	}
+
+	if outputDir == "" {
+		outputDir = filepath.Dir(input)
+	}
+	prefix := filepath.Join(outputDir, strings.TrimSuffix(filepath.Base(input), ".gguf"))
+
+	existing, _ := filepath.Glob(prefix + "-*-of-*.gguf")
+	if len(existing) > 0 {
+		fmt.Printf("Error: shards already exist at %s\n", existing[0])
+		return
+	}
+
+	binary, err := ensureLlamaBinary(ctx, "llama-gguf-split")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	splitArgs := []string{"--split"}
+	if maxSize != "" {
+		splitArgs = append(splitArgs, "--split-max-size", maxSize)
+	} else {
+		splitArgs = append(splitArgs, "--split-max-tensors", strconv.Itoa(maxTensors))
+	}
+	splitArgs = append(splitArgs, input, prefix)
+
+	err = runVerbose("", binary, splitArgs...)
+	if err != nil {
+		fmt.Printf("Error splitting %s: %v\n", input, err)
+		return
+	}
+
+	// Find out how many shards were written and check them
+	shards, _ := filepath.Glob(prefix + "-00001-of-*.gguf")
+	if len(shards) != 1 {
+		fmt.Printf("Error: could not find the first shard at %s-00001-of-*.gguf\n", prefix)
+		return
+	}
+	first := shards[0]
+
+	model, err := openValidModel(first)
+	if err != nil {
+		fmt.Printf("Error: the split output is invalid: %v\n", err)
+		return
+	}
+	fmt.Printf("Split into %d shards\n", len(model.Shards))
+
+	oldPaths := []string{input}
+	newPaths := gguf.ShardPaths(first)
+	finishReplace(ctx, oldPaths, newPaths, first, removeOriginal)
+}
+
+// runModelMerge merges the shards of a split model into a single file
+func runModelMerge(ctx *utils.Context, args []string) {
+	var target, output string
+	removeOriginal := false
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--output":
+			if i+1 >= len(args) {
+				fmt.Println("Missing value for --output")
+				return
+			}
+			output = ctx.Dirs.ExpandPath(args[i+1])
+			i++
+		case "--remove-original":
+			removeOriginal = true
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				fmt.Printf("Unknown option: %s\n", args[i])
+				fmt.Println("Usage: " + modelSubcommands["merge"].usage)
+				return
+			}
+			target = args[i]
+		}
+	}
+
+	if target == "" {
+		fmt.Println("Usage: " + modelSubcommands["merge"].usage)
+		return
+	}
+
+	path, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	shard, ok := gguf.ParseShardName(path)
+	if !ok {
+		fmt.Printf("Error: %s is not a split model\n", path)
+		return
+	}
+	first := gguf.ShardPath(shard.Prefix, 1, shard.Count)
+	oldPaths := gguf.ShardPaths(first)
+
+	// llama-gguf-split doesn't check every shard before writing
+	for _, shardPath := range oldPaths {
+		if !utils.FileExists(shardPath) {
+			fmt.Printf("Error: shard %s is missing\n", shardPath)
+			return
+		}
+	}
+	model, err := openValidModel(first)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Merging %d shards (%s)\n", len(model.Shards), utils.FormatBytes(model.FileSize()))
+
+	if output == "" {
+		output = shard.Prefix + ".gguf"
+	}
+	if utils.FileExists(output) {
+		fmt.Printf("Error: %s already exists\n", output)
+		return
+	}
+
+	binary, err := ensureLlamaBinary(ctx, "llama-gguf-split")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	err = runVerbose("", binary, "--merge", first, output)
+	if err != nil {
+		os.Remove(output)
+		fmt.Printf("Error merging %s: %v\n", first, err)
+		return
+	}
+
+	merged, err := openValidModel(output)
+	if err != nil {
+		fmt.Printf("Error: the merged output is invalid: %v\n", err)
+		return
+	}
+	if len(merged.Tensors()) != len(model.Tensors()) {
+		fmt.Printf("Error: the merged output has %d tensors, expected %d\n", len(merged.Tensors()), len(model.Tensors()))
+		return
+	}
+
+	finishReplace(ctx, oldPaths, []string{output}, output, removeOriginal)
+}
+
+// openValidModel opens a model and checks its shards are consistent
+func openValidModel(path string) (*gguf.Model, error) {
+	model, err := gguf.OpenModel(path)
+	if err != nil {
+		return nil, err
+	}
+	err = model.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// finishReplace indexes the new files, points presets at them and, when
+// removeOriginal is set, removes the old files
+func finishReplace(ctx *utils.Context, oldPaths, newPaths []string, model string, removeOriginal bool) {
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+	for _, path := range newPaths {
+		_, err = index.Add(path, false)
+		if err != nil {
+			fmt.Printf("Error indexing %s: %v\n", path, err)
+			return
+		}
+	}
+
+	updated, err := library.RepointPresets(ctx, oldPaths, model)
+	for _, name := range updated {
+		fmt.Printf("Updated preset %s\n", name)
+	}
+	if err != nil {
+		fmt.Printf("Error updating presets: %v\n", err)
+		if removeOriginal {
+			fmt.Println("Keeping the original files")
+		}
+		index.Save()
+		return
+	}
+
+	// The provenance of the original still describes the new files
+	if old := library.ProvenancePath(oldPaths[0]); utils.FileExists(old) && !utils.FileExists(library.ProvenancePath(model)) {
+		if removeOriginal {
+			os.Rename(old, library.ProvenancePath(model))
+		} else {
+			utils.CopyFile(old, library.ProvenancePath(model), 0644)
+		}
+	}
+
+	if removeOriginal {
+		for _, path := range oldPaths {
+			index.Remove(path)
+			err = os.Remove(path)
+			if err != nil {
+				fmt.Printf("Error removing %s: %v\n", path, err)
+				continue
+			}
+			os.Remove(library.ProvenancePath(path))
+		}
+	}
+
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Model written to %s\n", model)
+}
+
+// Register the model split and merge subcommands automatically
+func init() {
+	registerModelSubcommand("split",
+		"Split a model into shards with llama-gguf-split",
+		"llamarunner model split <file|preset> (--max-size <n>M|G | --max-tensors <n>) [--output-dir <dir>] [--remove-original]",
+		runModelSplit)
+	registerModelSubcommand("merge",
+		"Merge the shards of a split model into one file",
+		"llamarunner model merge <first-shard|preset> [--output <file>] [--remove-original]",
+		runModelMerge)
+}
@@ -0,0 +1,301 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// runModelAlias lists the store aliases, or imports a model into the store
+// under a new alias
+func runModelAlias(ctx *utils.Context, args []string) {
+	var opts library.StoreOptions
+	var positional []string
+
+	for _, arg := range args {
+		switch arg {
+		case "--move":
+			opts.Move = true
+		case "--force":
+			opts.Force = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Printf("Unknown option: %s\n", arg)
+				fmt.Println("Usage: " + modelSubcommands["alias"].usage)
+				return
+			}
+			positional = append(positional, arg)
+		}
+	}
+
+	switch len(positional) {
+	case 0:
+		listAliases(ctx)
+		return
+	case 2:
+	default:
+		fmt.Println("Usage: " + modelSubcommands["alias"].usage)
+		return
+	}
+	name, target := positional[0], positional[1]
+
+	model, err := resolveModelArg(ctx, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if shard, ok := gguf.ParseShardName(model); ok {
+		model = gguf.ShardPath(shard.Prefix, 1, shard.Count)
+	}
+
+	alias, err := library.AddAlias(ctx, name, model, opts)
+	if err != nil {
+		fmt.Printf("Error adding alias: %v\n", err)
+		return
+	}
+	fmt.Printf("Stored %s as %s (%s)\n", filepath.Base(model), name, utils.FormatBytes(alias.Size))
+
+	if !opts.Move {
+		fmt.Printf("Presets can now use model=%s\n", name)
+		return
+	}
+
+	// The original files are gone, so presets must use the alias
+	updated, err := library.RepointPresets(ctx, gguf.ShardPaths(model), name)
+	if err != nil {
+		fmt.Printf("Error updating presets: %v\n", err)
+	}
+	for _, preset := range updated {
+		fmt.Printf("Updated preset %s to use %s\n", preset, name)
+	}
+}
+
+// listAliases prints every alias with its size and the presets using it
+func listAliases(ctx *utils.Context) {
+	aliases, err := library.ListAliases(ctx)
+	if err != nil {
+		fmt.Printf("Error reading the model store: %v\n", err)
+		return
+	}
+	if len(aliases) == 0 {
+		fmt.Println("No aliases. Add one with 'llamarunner model alias <name> <file|preset>'")
+		return
+	}
+
+	fmt.Printf("%-30s %10s %6s  %s\n", "ALIAS", "SIZE", "FILES", "PRESETS")
+	for _, alias := range aliases {
+		presets, err := library.AliasPresets(ctx, alias.Name)
+		if err != nil {
+			fmt.Printf("Error reading presets: %v\n", err)
+			return
+		}
+		fmt.Printf("%-30s %10s %6d  %s\n", alias.Name, utils.FormatBytes(alias.Size), len(alias.Files), strings.Join(presets, ", "))
+	}
+}
+
+// runModelRm removes an alias or a model file with its shards, refusing
+// while a preset uses it unless forced
+func runModelRm(ctx *utils.Context, args []string) {
+	var target string
+	force := false
+
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			force = true
+		default:
+			if strings.HasPrefix(arg, "-") || target != "" {
+				fmt.Println("Usage: " + modelSubcommands["rm"].usage)
+				return
+			}
+			target = arg
+		}
+	}
+	if target == "" {
+		fmt.Println("Usage: " + modelSubcommands["rm"].usage)
+		return
+	}
+
+	if _, ok := utils.ResolveAlias(ctx, target); ok {
+		removeAlias(ctx, target, force)
+		return
+	}
+
+	path := ctx.Dirs.ExpandPath(target)
+	if !utils.FileExists(path) {
+		fmt.Printf("Error: %s is neither an alias nor a model file\n", target)
+		return
+	}
+	removeModelFiles(ctx, path, force)
+}
+
+// removeAlias deletes an alias, leaving its blobs for model gc
+func removeAlias(ctx *utils.Context, name string, force bool) {
+	presets, err := deleteAlias(ctx, name, force)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Removed alias %s\n", name)
+	if len(presets) > 0 {
+		fmt.Printf("Warning: preset(s) %s no longer have a model\n", strings.Join(presets, ", "))
+	}
+	fmt.Println("Run 'llamarunner model gc' to reclaim the space of unused files")
+}
+
+// deleteAlias deletes an alias unless a preset uses it and force is unset,
+// and returns the presets that used it
+func deleteAlias(ctx *utils.Context, name string, force bool) ([]string, error) {
+	presets, err := library.AliasPresets(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading presets: %v", err)
+	}
+	if len(presets) > 0 && !force {
+		return nil, fmt.Errorf("%s is used by preset(s) %s (use 'llamarunner model rm --force' to remove it anyway)", name, strings.Join(presets, ", "))
+	}
+
+	err = library.RemoveAlias(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error removing alias: %v", err)
+	}
+	return presets, nil
+}
+
+// removeModelFiles deletes a model file, every shard of a split model and
+// their provenance files
+func removeModelFiles(ctx *utils.Context, path string, force bool) {
+	removal, err := deleteModelFiles(ctx, path, force)
+	for _, file := range removal.files {
+		fmt.Printf("Removed %s\n", file)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Freed %s\n", utils.FormatBytes(removal.freed))
+	if len(removal.presets) > 0 {
+		fmt.Printf("Warning: preset(s) %s now point at a missing model\n", strings.Join(removal.presets, ", "))
+	}
+}
+
+// modelRemoval is what deleteModelFiles did
+type modelRemoval struct {
+	files []string
+	freed int64
+
+	// presets used the model and now point at a missing file
+	presets []string
+}
+
+// deleteModelFiles deletes a model file, every shard of a split model and
+// their provenance files, unless a preset uses them and force is unset
+func deleteModelFiles(ctx *utils.Context, path string, force bool) (modelRemoval, error) {
+	var removal modelRemoval
+
+	if shard, ok := gguf.ParseShardName(path); ok {
+		path = gguf.ShardPath(shard.Prefix, 1, shard.Count)
+	}
+	files := gguf.ShardPaths(path)
+
+	// Removing a blob would break the aliases sharing it
+	for _, file := range files {
+		if library.IsBlob(ctx, file) {
+			return removal, fmt.Errorf("%s is in the model store; remove its alias instead", file)
+		}
+	}
+
+	presets, err := library.FilePresets(ctx, files)
+	if err != nil {
+		return removal, fmt.Errorf("error reading presets: %v", err)
+	}
+	if len(presets) > 0 && !force {
+		return removal, fmt.Errorf("%s is used by preset(s) %s (use 'llamarunner model rm --force' to remove it anyway)", path, strings.Join(presets, ", "))
+	}
+	removal.presets = presets
+
+	index, err := library.LoadIndex(ctx)
+	if err != nil {
+		return removal, fmt.Errorf("error loading model index: %v", err)
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		index.Remove(file)
+		err = os.Remove(file)
+		if err != nil {
+			index.Save()
+			return removal, fmt.Errorf("error removing %s: %v", file, err)
+		}
+		os.Remove(library.ProvenancePath(file))
+		removal.freed += info.Size()
+		removal.files = append(removal.files, file)
+	}
+
+	err = index.Save()
+	if err != nil {
+		return removal, fmt.Errorf("error saving model index: %v", err)
+	}
+	return removal, nil
+}
+
+// runModelGC removes store files no alias uses
+func runModelGC(ctx *utils.Context, args []string) {
+	dryRun := false
+	for _, arg := range args {
+		switch arg {
+		case "--dry-run":
+			dryRun = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println("Usage: " + modelSubcommands["gc"].usage)
+			return
+		}
+	}
+
+	removed, freed, err := library.CollectGarbage(ctx, dryRun)
+	for _, path := range removed {
+		if dryRun {
+			fmt.Printf("Would remove %s\n", path)
+		} else {
+			fmt.Printf("Removed %s\n", path)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error collecting unused files: %v\n", err)
+		return
+	}
+
+	switch {
+	case len(removed) == 0:
+		fmt.Println("Nothing to remove")
+	case dryRun:
+		fmt.Printf("%d unused file(s), %s\n", len(removed), utils.FormatBytes(freed))
+	default:
+		fmt.Printf("Removed %d unused file(s), %s\n", len(removed), utils.FormatBytes(freed))
+	}
+}
+
+// Register the model store subcommands automatically
+func init() {
+	registerModelSubcommand("alias",
+		"List store aliases, or store a model under an alias presets can use",
+		"llamarunner model alias [<name> <file|preset> [--move] [--force]]",
+		runModelAlias)
+	registerModelSubcommand("rm",
+		"Remove an alias or a model file unless a preset uses it",
+		"llamarunner model rm <alias|file> [--force]",
+		runModelRm)
+	registerModelSubcommand("gc",
+		"Remove files in the model store no alias uses",
+		"llamarunner model gc [--dry-run]",
+		runModelGC)
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/library"
+	"github/llamarunner/utils"
+)
+
+// verifyResult collects the problems found in one model
+type verifyResult struct {
+	problems []string
+	hints    []string
+}
+
+// fail records a problem with an optional repair hint
+func (r *verifyResult) fail(problem, hint string) {
+	r.problems = append(r.problems, problem)
+	if hint != "" && !containsString(r.hints, hint) {
+		r.hints = append(r.hints, hint)
+	}
+}
+
+// runModelVerify checks GGUF structure, completeness and recorded hashes of
+// one model or the whole library
+func runModelVerify(ctx *utils.Context, args []string) {
+	var target string
+	all, hash := false, true
+
+	for _, arg := range args {
+		switch arg {
+		case "--all":
+			all = true
+		case "--no-hash":
+			hash = false
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Printf("Unknown option: %s\n", arg)
+				fmt.Println("Usage: " + modelSubcommands["verify"].usage)
+				return
+			}
+			target = arg
+		}
+	}
+
+	if (target == "") == !all {
+		fmt.Println("Usage: " + modelSubcommands["verify"].usage)
+		return
+	}
+
+	// Keep the hashes as recorded before scanning, since a scan replaces
+	// the entries of changed files
+	recorded, err := library.LoadIndex(ctx)
+	if err != nil {
+		fmt.Printf("Error loading model index: %v\n", err)
+		return
+	}
+
+	index := recorded
+	var models []string
+	if all {
+		index, err = library.Scan(ctx, library.ScanOptions{NoHash: true})
+		if err != nil {
+			fmt.Printf("Error scanning models: %v\n", err)
+			return
+		}
+		models = verifyTargets(index)
+	} else {
+		path, err := resolveModelArg(ctx, target)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		models = []string{path}
+	}
+
+	failed := 0
+	for _, model := range models {
+		result := verifyModel(index, recorded, model, hash)
+		if len(result.problems) == 0 {
+			fmt.Printf("OK    %s\n", model)
+			continue
+		}
+
+		failed++
+		fmt.Printf("FAIL  %s\n", model)
+		for _, problem := range result.problems {
+			fmt.Printf("      - %s\n", problem)
+		}
+		for _, hint := range result.hints {
+			fmt.Printf("      Hint: %s\n", hint)
+		}
+	}
+
+	err = index.Save()
+	if err != nil {
+		fmt.Printf("Error saving model index: %v\n", err)
+	}
+
+	fmt.Printf("\n%d model(s) checked, %d with problems\n", len(models), failed)
+}
+
+// verifyTargets returns one path per model in the index, using the first
+// shard for split models
+func verifyTargets(index *library.Index) []string {
+	seen := map[string]bool{}
+	var models []string
+	for _, entry := range index.Entries {
+		path := entry.Path
+		if shard, ok := gguf.ParseShardName(path); ok {
+			path = gguf.ShardPath(shard.Prefix, 1, shard.Count)
+		}
+		if !seen[path] {
+			seen[path] = true
+			models = append(models, path)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// verifyModel checks every shard of the model at path against the hashes in
+// recorded, adding missing hashes to index
+func verifyModel(index, recorded *library.Index, path string, hash bool) *verifyResult {
+	result := &verifyResult{}
+
+	var files []*gguf.File
+	shardPaths := gguf.ShardPaths(path)
+	for i, shardPath := range shardPaths {
+		label := shardPath
+		if len(shardPaths) > 1 {
+			label = fmt.Sprintf("shard %d of %d", i+1, len(shardPaths))
+		}
+
+		if !utils.FileExists(shardPath) {
+			result.fail(label+" is missing: "+shardPath, "download or copy the missing shard, or re-download the whole model")
+			continue
+		}
+
+		file := verifyFile(index, recorded, shardPath, label, hash, result)
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+
+	// Shard numbering is only meaningful when every shard could be read
+	if len(shardPaths) > 1 && len(files) == len(shardPaths) {
+		err := (&gguf.Model{Shards: files}).Validate()
+		if err != nil {
+			result.fail(err.Error(), "the shards come from different split runs; re-download or re-split the model")
+		}
+	} else if len(shardPaths) == 1 && len(files) == 1 {
+		if count, ok := files[0].GetUint(gguf.KeySplitCount); ok && count > 1 {
+			result.fail(fmt.Sprintf("file is shard of a %d-part split but is not named -00001-of-%05d.gguf", count, count),
+				"rename the shards to the -NNNNN-of-NNNNN.gguf pattern or merge them")
+		}
+	}
+
+	return result
+}
+
+// verifyFile checks the structure and recorded hash of a single file,
+// returning the parsed header if it could be read
+func verifyFile(index, recorded *library.Index, path, label string, hash bool, result *verifyResult) *gguf.File {
+	file, err := gguf.Open(path)
+	if err != nil {
+		hint := "the file is not a valid GGUF model; re-download or re-convert it"
+		if strings.Contains(err.Error(), "truncated") || strings.Contains(err.Error(), "exceeds file size") {
+			hint = "the download was probably interrupted; run 'llamarunner model pull' again or re-download the file"
+		}
+		// Open errors already name the file
+		problem := err.Error()
+		if label != path {
+			problem = label + ": " + problem
+		}
+		result.fail(problem, hint)
+		return nil
+	}
+
+	for _, problem := range file.Check() {
+		hint := "the file is corrupt; re-download or re-convert it"
+		if file.Truncated() {
+			hint = "the download was probably interrupted; run 'llamarunner model pull' again or re-download the file"
+		}
+		result.fail(label+": "+problem, hint)
+	}
+
+	if !hash {
+		return file
+	}
+
+	entry, indexed := recorded.Lookup(path)
+	if !indexed || entry.SHA256 == "" {
+		// Nothing to compare against; record the hash for next time if the
+		// file looks sound
+		if len(result.problems) == 0 {
+			fmt.Printf("Hashing %s...\n", path)
+			_, err = index.Add(path, true)
+			if err != nil {
+				result.fail(fmt.Sprintf("%s: %v", label, err), "")
+			}
+		}
+		return file
+	}
+
+	fmt.Printf("Hashing %s...\n", path)
+	sum, err := utils.HashFile(path)
+	if err != nil {
+		result.fail(fmt.Sprintf("%s: %v", label, err), "")
+		return file
+	}
+	if sum == entry.SHA256 {
+		return file
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && (info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime)) {
+		result.fail(fmt.Sprintf("%s: sha256 differs from the index; the file was modified on %s", label, info.ModTime().Format("2006-01-02 15:04")),
+			"if the change was intended, run 'llamarunner model list --rescan' to record the new hash")
+	} else {
+		result.fail(fmt.Sprintf("%s: sha256 %s does not match %s recorded in the index", label, sum, entry.SHA256),
+			"the file changed on disk without its timestamp changing, which points to disk corruption; re-download it")
+	}
+	return file
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Register the model verify subcommand automatically
+func init() {
+	registerModelSubcommand("verify",
+		"Check model files for corruption, truncation and hash mismatches",
+		"llamarunner model verify <file|preset> | --all [--no-hash]",
+		runModelVerify)
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+
+	"github/llamarunner/builder"
+)
+
+// runPreflight prints the checklist of build prerequisites with the
+// packages providing the missing ones, and reports whether every required
+// item is present
+func runPreflight(opts builder.PreflightOptions) bool {
+	distro := builder.DetectDistro(opts.Env.Root)
+	checks := builder.Preflight(opts)
+
+	fmt.Printf("Checking build prerequisites on %s:\n", distro.Name)
+
+	var packages, hints []string
+	for _, check := range checks {
+		status := "ok"
+		switch {
+		case !check.OK && check.Optional:
+			status = "optional"
+		case !check.OK:
+			status = "MISSING"
+		}
+
+		line := fmt.Sprintf("  %-9s %s", status, check.Name)
+		if check.Detail != "" {
+			line += " (" + check.Detail + ")"
+		}
+		fmt.Println(line)
+
+		if check.OK {
+			continue
+		}
+		if names, ok := check.Packages[distro.Family]; ok && distro.Family != "" {
+			for _, name := range names {
+				if !containsString(packages, name) {
+					packages = append(packages, name)
+				}
+			}
+		} else if check.Hint != "" && !containsString(hints, check.Hint) {
+			hints = append(hints, check.Hint)
+		}
+	}
+
+	if command := distro.InstallCommand(packages); command != "" {
+		fmt.Printf("Install the missing packages with:\n  %s\n", command)
+	} else if distro.Family == "" && !allOK(checks) {
+		fmt.Println("Install the missing items with your distribution's package manager.")
+	}
+	for _, hint := range hints {
+		fmt.Printf("Hint: %s\n", hint)
+	}
+
+	return !builder.Failed(checks)
+}
+
+// allOK reports whether every check passed, optional ones included
+func allOK(checks []builder.Check) bool {
+	for _, check := range checks {
+		if !check.OK {
+			return false
+		}
+	}
+	return true
+}
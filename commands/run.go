@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github/llamarunner/builder"
+	"github/llamarunner/gguf"
 	"github/llamarunner/utils"
 )
 
@@ -20,20 +23,42 @@ func NewRunCommand() *RunCommand {
 		BaseCommand: NewBaseCommand(
 			"run",
 			"Load model with preset",
-			"llamarunner run <preset-name>",
+			"llamarunner run <preset-name> [--force] [--dry-run]\nOptions:\n  --force    Launch even if the model is not expected to fit in memory\n  --dry-run  Show the command and the llama.cpp build it would use without launching",
 		),
 	}
 }
 
 // Run executes the run command
-func (c *RunCommand) Run(args []string) {
+func (c *RunCommand) Run(ctx *utils.Context, args []string) {
 	if len(args) < 1 {
 		fmt.Println(c.Usage())
 		return
 	}
 	presetName := args[0]
+
+	force := false
+	dryRun := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--force":
+			force = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println(c.Usage())
+			return
+		}
+	}
+
+	// Refuse to start a model that will not fit before the OOM killer does
+	if !checkMemory(ctx, presetName) && !force && !dryRun {
+		fmt.Println("Refusing to launch. Use --force to launch anyway.")
+		return
+	}
+
 	// Load the enhanced preset configuration
-	preset, err := utils.LoadPresetConfig(presetName)
+	preset, err := utils.LoadPresetConfig(ctx, presetName)
 	if err != nil {
 		fmt.Printf("Error loading preset config: %v\n", err)
 		return
@@ -54,6 +79,12 @@ func (c *RunCommand) Run(args []string) {
 	// Extract arguments (everything after the binary path)
 	runArgs := parts[1:]
 
+	if dryRun {
+		fmt.Printf("Command:        %s\n", commandLine)
+		printBinaryBuild(binaryPath)
+		return
+	}
+
 	// Build command with direct argument passing
 	cmd := exec.Command(binaryPath, runArgs...)
 
@@ -69,6 +100,104 @@ func (c *RunCommand) Run(args []string) {
 	}
 }
 
+// printBinaryBuild shows the manifest of the build a llama.cpp binary in
+// build/bin comes from, and warns when the binary changed since
+func printBinaryBuild(binaryPath string) {
+	buildDir := filepath.Dir(filepath.Dir(binaryPath))
+
+	manifest, err := builder.LoadManifest(buildDir)
+	if err != nil {
+		fmt.Printf("Warning: could not read the build manifest: %v\n", err)
+		return
+	}
+	if manifest == nil {
+		fmt.Printf("No build manifest in %s; rebuild with 'llamarunner build' to record one\n", buildDir)
+		return
+	}
+
+	fmt.Printf("Build:          %s\n", buildDir)
+	printBuildManifest(manifest)
+
+	name := filepath.Base(binaryPath)
+	recorded := manifest.Binary(name)
+	hash, err := utils.HashFile(binaryPath)
+	switch {
+	case err != nil:
+		fmt.Printf("Warning: %v\n", err)
+	case recorded == nil:
+		fmt.Printf("Warning: %s was not part of this build\n", name)
+	case recorded.SHA256 != hash:
+		fmt.Printf("Warning: %s changed since this build; the manifest may not describe it\n", name)
+	case recorded.Commit != "":
+		fmt.Printf("Note: %s was built later, from commit %s\n", name, recorded.Commit)
+	}
+}
+
+// memoryWarnRatio is the share of available RAM above which run warns
+const memoryWarnRatio = 0.9
+
+// checkMemory estimates the preset's memory use and compares it with the
+// available RAM, returning false if it is not expected to fit. Presets whose
+// model cannot be read are not checked.
+func checkMemory(ctx *utils.Context, presetName string) bool {
+	preset, err := utils.LoadPreset(ctx, presetName)
+	if err != nil {
+		return true
+	}
+
+	modelPath := preset.ModelPath(ctx)
+	if modelPath == "" {
+		return true
+	}
+
+	model, err := gguf.OpenModel(modelPath)
+	if err != nil {
+		fmt.Printf("Warning: skipping memory check: %v\n", err)
+		fmt.Println("Run 'llamarunner model verify " + presetName + "' to check the model file.")
+		return true
+	}
+
+	// A half-downloaded model makes llama-server crash with a cryptic error
+	for _, shard := range model.Shards {
+		if shard.Truncated() {
+			fmt.Printf("Warning: %s is truncated (%s of %s)\n", shard.Path,
+				utils.FormatBytes(shard.FileSize), utils.FormatBytes(shard.ExpectedSize()))
+			fmt.Println("Run 'llamarunner model verify " + presetName + "' for details.")
+		}
+	}
+
+	memInfo, err := utils.ReadMemInfo("/proc/meminfo")
+	if err != nil {
+		fmt.Printf("Warning: skipping memory check: %v\n", err)
+		return true
+	}
+
+	estimate := utils.EstimateMemory(model, utils.MemoryOptionsFromPreset(preset))
+	host := estimate.Host()
+
+	fmt.Printf("Estimated memory: weights %s, KV cache %s (context %d over %d slot(s)), compute %s\n",
+		utils.FormatBytes(estimate.Weights), utils.FormatBytes(estimate.KVCache),
+		estimate.ContextSize, estimate.Parallel, utils.FormatBytes(estimate.Compute))
+	if estimate.GPUFraction > 0 {
+		fmt.Printf("Offloading %.0f%% of layers: about %s in GPU memory, %s in RAM\n",
+			estimate.GPUFraction*100, utils.FormatBytes(estimate.GPU()), utils.FormatBytes(host))
+	}
+
+	available := memInfo.Available
+	switch {
+	case host > available:
+		fmt.Printf("Error: %s needs about %s of RAM but only %s is available\n",
+			presetName, utils.FormatBytes(host), utils.FormatBytes(available))
+		fmt.Println("Reduce ctx_size, use a smaller quantization or KV cache type, or offload more layers to the GPU.")
+		return false
+	case float64(host) > float64(available)*memoryWarnRatio:
+		fmt.Printf("Warning: %s needs about %s of RAM and only %s is available\n",
+			presetName, utils.FormatBytes(host), utils.FormatBytes(available))
+	}
+
+	return true
+}
+
 // Register the run command automatically
 func init() {
 	RegisterCommand("run", NewRunCommand())
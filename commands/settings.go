@@ -16,13 +16,13 @@ func NewSetCommand() *SetCommand {
 		BaseCommand: NewBaseCommand(
 			"set",
 			"Manage configuration settings",
-			"llamarunner set <target>\nTargets:\n  d    Set default settings\n  e    Edit settings file",
+			"llamarunner set <target>\nTargets:\n  d    Set default settings\n  e    Edit settings file in $VISUAL/$EDITOR",
 		),
 	}
 }
 
 // Run executes the set command
-func (c *SetCommand) Run(args []string) {
+func (c *SetCommand) Run(ctx *utils.Context, args []string) {
 	if len(args) < 1 {
 		fmt.Println(c.Usage())
 		return
@@ -30,15 +30,62 @@ func (c *SetCommand) Run(args []string) {
 	target := args[0]
 	switch target {
 	case "d":
-		utils.SetDefaultSettings()
+		utils.SetDefaultSettings(ctx)
 	case "e":
-		utils.EditSettingsFile()
+		utils.EditSettingsFile(ctx)
 	default:
 		fmt.Printf("Unknown target: %s\n", target)
 	}
 }
 
-// Register the set command automatically
+// SettingsCommand implements the Command interface for inspecting the
+// effective, layered settings
+type SettingsCommand struct {
+	*BaseCommand
+}
+
+// NewSettingsCommand creates a new settings command
+func NewSettingsCommand() *SettingsCommand {
+	return &SettingsCommand{
+		BaseCommand: NewBaseCommand(
+			"settings",
+			"Show effective settings",
+			"llamarunner settings list [--show-origin]\nLayers, lowest to highest precedence:\n  "+utils.SystemSettingsFile+"\n  $XDG_CONFIG_HOME/llamarunner/settings.toml (or ~/.llama-presets/settings.toml)\n  "+utils.ProjectSettingsFile+" in the working directory or a parent\n  "+utils.EnvPrefix+"<KEY> environment variables\n  llamarunner --set <key>=<value> <command>",
+		),
+	}
+}
+
+// Run executes the settings command
+func (c *SettingsCommand) Run(ctx *utils.Context, args []string) {
+	if len(args) < 1 || args[0] != "list" {
+		fmt.Println(c.Usage())
+		return
+	}
+
+	showOrigin := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--show-origin":
+			showOrigin = true
+		default:
+			fmt.Printf("Unknown option: %s\n", arg)
+			fmt.Println(c.Usage())
+			return
+		}
+	}
+
+	for _, key := range utils.SettingKeys() {
+		value := fmt.Sprintf("%s = %#v", key, utils.GetSettingValue(ctx.Settings, key))
+		if showOrigin {
+			fmt.Printf("%-60s %s\n", ctx.Origins[key], value)
+		} else {
+			fmt.Println(value)
+		}
+	}
+}
+
+// Register the settings commands automatically
 func init() {
 	RegisterCommand("set", NewSetCommand())
+	RegisterCommand("settings", NewSettingsCommand())
 }
@@ -24,7 +24,7 @@ func NewUpdateCommand() *UpdateCommand {
 }
 
 // Run executes the update command
-func (c *UpdateCommand) Run(args []string) {
+func (c *UpdateCommand) Run(ctx *utils.Context, args []string) {
 	checkOnly := false
 	forceUpdate := false
 
@@ -52,14 +52,7 @@ func (c *UpdateCommand) Run(args []string) {
 		return
 	}
 
-	// Load current settings to get version
-	settings, err := utils.LoadSettings()
-	if err != nil {
-		fmt.Printf("Error loading settings: %v\n", err)
-		return
-	}
-
-	currentVersion := settings.Version
+	currentVersion := ctx.Settings.Version
 	latestVersion := release.TagName
 
 	fmt.Printf("Current version: %s\n", currentVersion)
@@ -86,8 +79,8 @@ func (c *UpdateCommand) Run(args []string) {
 	}
 
 	// Update version in settings
-	settings.Version = latestVersion
-	err = utils.SaveSettings(settings)
+	ctx.Settings.Version = latestVersion
+	err = utils.SaveSettings(ctx)
 	if err != nil {
 		fmt.Printf("Warning: Could not update version in settings: %v\n", err)
 	} else {
@@ -0,0 +1,268 @@
+// Package gguf reads the header, metadata and tensor table of GGUF model
+// files without loading tensor data.
+package gguf
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Magic is the little-endian "GGUF" marker at the start of every file
+const Magic = 0x46554747
+
+// DefaultAlignment is used when general.alignment is not set
+const DefaultAlignment = 32
+
+// KV is a single metadata key/value pair
+type KV struct {
+	Key   string
+	Type  ValueType
+	Value interface{}
+}
+
+// Array is the value of an array metadata entry
+type Array struct {
+	Type   ValueType
+	Values []interface{}
+}
+
+// TensorInfo describes one tensor in the tensor table
+type TensorInfo struct {
+	Name       string
+	Dimensions []uint64
+	Type       TensorType
+
+	// Offset is relative to the start of the data section
+	Offset uint64
+}
+
+// Elements returns the number of elements in the tensor, or math.MaxUint64
+// when the dimensions multiply past it
+func (t TensorInfo) Elements() uint64 {
+	count := uint64(1)
+	for _, dim := range t.Dimensions {
+		hi, lo := bits.Mul64(count, dim)
+		if hi != 0 {
+			return math.MaxUint64
+		}
+		count = lo
+	}
+	return count
+}
+
+// Size returns the number of bytes the tensor occupies in the data section,
+// 0 when the type's layout is unknown, or math.MaxUint64 when it overflows
+func (t TensorInfo) Size() uint64 {
+	info, ok := tensorTypes[t.Type]
+	if !ok {
+		return 0
+	}
+	hi, lo := bits.Mul64(t.Elements()/uint64(info.blockSize), uint64(info.typeSize))
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// File is the parsed header of a single GGUF file
+type File struct {
+	Path    string
+	Version uint32
+
+	// Metadata keeps key/value pairs in file order
+	Metadata []KV
+	Tensors  []TensorInfo
+
+	// Alignment of the data section and of each tensor within it
+	Alignment uint64
+
+	// HeaderSize is where the tensor table ends, before alignment padding
+	HeaderSize int64
+
+	// DataOffset is where the data section starts in the file
+	DataOffset int64
+
+	// FileSize is the size of the file on disk
+	FileSize int64
+}
+
+// Get returns the metadata value for key
+func (f *File) Get(key string) (interface{}, bool) {
+	for _, kv := range f.Metadata {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetString returns a string metadata value, or "" if missing
+func (f *File) GetString(key string) string {
+	value, _ := f.Get(key)
+	str, _ := value.(string)
+	return str
+}
+
+// GetUint returns an integer metadata value of any width, or false if the key
+// is missing or not an integer
+func (f *File) GetUint(key string) (uint64, bool) {
+	value, ok := f.Get(key)
+	if !ok {
+		return 0, false
+	}
+	return toUint(value)
+}
+
+// GetArray returns an array metadata value
+func (f *File) GetArray(key string) (*Array, bool) {
+	value, _ := f.Get(key)
+	array, ok := value.(*Array)
+	return array, ok
+}
+
+// Architecture returns general.architecture, e.g. "llama"
+func (f *File) Architecture() string {
+	return f.GetString("general.architecture")
+}
+
+// ArchUint returns an architecture-specific integer such as
+// "<arch>.context_length"
+func (f *File) ArchUint(suffix string) (uint64, bool) {
+	return f.GetUint(f.Architecture() + "." + suffix)
+}
+
+// FileType returns the quantization name from general.file_type
+func (f *File) FileType() string {
+	fileType, ok := f.GetUint("general.file_type")
+	if !ok {
+		return ""
+	}
+	return FileTypeName(fileType)
+}
+
+// ChatTemplate returns the embedded Jinja chat template, if any
+func (f *File) ChatTemplate() string {
+	return f.GetString("tokenizer.chat_template")
+}
+
+// TensorGroup summarizes the storage types used by tensors with the same
+// role, e.g. "attn_q.weight" across all blocks
+type TensorGroup struct {
+	Name     string
+	Count    int
+	Types    map[TensorType]int
+	Elements uint64
+	Size     uint64
+}
+
+// TypeSummary formats the types in the group, most common first
+func (g TensorGroup) TypeSummary() string {
+	types := make([]TensorType, 0, len(g.Types))
+	for t := range g.Types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if g.Types[types[i]] != g.Types[types[j]] {
+			return g.Types[types[i]] > g.Types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+
+	parts := make([]string, 0, len(types))
+	for _, t := range types {
+		parts = append(parts, fmt.Sprintf("%s x%d", t, g.Types[t]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GroupTensors groups tensors by name with the "blk.N." prefix removed
+func GroupTensors(tensors []TensorInfo) []TensorGroup {
+	groups := map[string]*TensorGroup{}
+	var order []string
+
+	for _, tensor := range tensors {
+		name := tensorGroupName(tensor.Name)
+		group, ok := groups[name]
+		if !ok {
+			group = &TensorGroup{Name: name, Types: map[TensorType]int{}}
+			groups[name] = group
+			order = append(order, name)
+		}
+
+		group.Count++
+		group.Types[tensor.Type]++
+		group.Elements += tensor.Elements()
+		group.Size += tensor.Size()
+	}
+
+	result := make([]TensorGroup, 0, len(order))
+	for _, name := range order {
+		result = append(result, *groups[name])
+	}
+	return result
+}
+
+// tensorGroupName strips the per-layer prefix from a tensor name
+func tensorGroupName(name string) string {
+	if !strings.HasPrefix(name, "blk.") {
+		return name
+	}
+
+	rest := strings.TrimPrefix(name, "blk.")
+	if dot := strings.IndexByte(rest, '.'); dot >= 0 {
+		return rest[dot+1:]
+	}
+	return name
+}
+
+// toUint converts any integer metadata value to uint64
+func toUint(value interface{}) (uint64, bool) {
+	switch v := value.(type) {
+	case uint8:
+		return uint64(v), true
+	case int8:
+		return uint64(v), v >= 0
+	case uint16:
+		return uint64(v), true
+	case int16:
+		return uint64(v), v >= 0
+	case uint32:
+		return uint64(v), true
+	case int32:
+		return uint64(v), v >= 0
+	case uint64:
+		return v, true
+	case int64:
+		return uint64(v), v >= 0
+	}
+	return 0, false
+}
+
+// FormatValue formats a metadata value for display, summarizing arrays and
+// shortening long strings to maxLen characters (0 for no limit)
+func FormatValue(value interface{}, maxLen int) string {
+	var text string
+	switch v := value.(type) {
+	case *Array:
+		if len(v.Values) > 8 {
+			return fmt.Sprintf("[%s x %d]", v.Type, len(v.Values))
+		}
+		parts := make([]string, 0, len(v.Values))
+		for _, elem := range v.Values {
+			parts = append(parts, FormatValue(elem, maxLen))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case string:
+		text = fmt.Sprintf("%q", v)
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+
+	if maxLen > 0 && len(text) > maxLen {
+		text = text[:maxLen] + "..."
+	}
+	return text
+}
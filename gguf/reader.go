@@ -0,0 +1,362 @@
+package gguf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// maxStringLength guards against corrupt length fields; chat templates and
+// token strings are far smaller than this
+const maxStringLength = 64 << 20
+
+// maxArrayDepth limits arrays of arrays, which llama.cpp never nests deeply,
+// so a crafted file can't exhaust the stack
+const maxArrayDepth = 4
+
+// reader decodes little-endian GGUF primitives while tracking the offset
+type reader struct {
+	r       *bufio.Reader
+	offset  int64
+	size    int64
+	version uint32
+	depth   int
+}
+
+// Open parses the header, metadata and tensor table of the GGUF file at path
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := Read(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	file.Path = path
+
+	return file, nil
+}
+
+// Read parses a GGUF header from r; size is the total file size, used to
+// reject lengths that point past the end of the file
+func Read(r io.Reader, size int64) (*File, error) {
+	rd := &reader{r: bufio.NewReaderSize(r, 1<<20), size: size}
+
+	magic, err := rd.uint32()
+	if err != nil {
+		return nil, fmt.Errorf("reading magic: %v", err)
+	}
+	if magic != Magic {
+		return nil, fmt.Errorf("not a GGUF file (magic %#08x)", magic)
+	}
+
+	version, err := rd.uint32()
+	if err != nil {
+		return nil, fmt.Errorf("reading version: %v", err)
+	}
+	if version < 1 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+	rd.version = version
+
+	tensorCount, err := rd.count()
+	if err != nil {
+		return nil, fmt.Errorf("reading tensor count: %v", err)
+	}
+	kvCount, err := rd.count()
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata count: %v", err)
+	}
+
+	// A metadata entry is at least a key length, a type and one byte of
+	// value; a tensor info at least a name length, a dimension count, a
+	// type and an offset
+	if err := rd.checkRemaining(kvCount, rd.countSize()+4+1); err != nil {
+		return nil, fmt.Errorf("metadata count: %v", err)
+	}
+	if err := rd.checkRemaining(tensorCount, rd.countSize()+4+4+8); err != nil {
+		return nil, fmt.Errorf("tensor count: %v", err)
+	}
+
+	file := &File{
+		Version:   version,
+		Alignment: DefaultAlignment,
+		FileSize:  size,
+	}
+
+	for i := uint64(0); i < kvCount; i++ {
+		kv, err := rd.kv()
+		if err != nil {
+			return nil, fmt.Errorf("reading metadata entry %d: %v", i, err)
+		}
+		file.Metadata = append(file.Metadata, kv)
+	}
+
+	if alignment, ok := file.GetUint("general.alignment"); ok {
+		if alignment == 0 || alignment&(alignment-1) != 0 {
+			return nil, fmt.Errorf("invalid general.alignment %d", alignment)
+		}
+		file.Alignment = alignment
+	}
+
+	for i := uint64(0); i < tensorCount; i++ {
+		tensor, err := rd.tensorInfo()
+		if err != nil {
+			return nil, fmt.Errorf("reading tensor info %d: %v", i, err)
+		}
+		file.Tensors = append(file.Tensors, tensor)
+	}
+
+	file.HeaderSize = rd.offset
+	file.DataOffset = AlignOffset(rd.offset, file.Alignment)
+
+	return file, nil
+}
+
+// AlignOffset rounds offset up to the next multiple of alignment
+func AlignOffset(offset int64, alignment uint64) int64 {
+	a := int64(alignment)
+	return (offset + a - 1) / a * a
+}
+
+// read fills buf completely, advancing the offset
+func (rd *reader) read(buf []byte) error {
+	n, err := io.ReadFull(rd.r, buf)
+	rd.offset += int64(n)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return fmt.Errorf("unexpected end of file at offset %d (truncated?)", rd.offset)
+	}
+	return err
+}
+
+func (rd *reader) uint8() (uint8, error) {
+	var buf [1]byte
+	err := rd.read(buf[:])
+	return buf[0], err
+}
+
+func (rd *reader) uint16() (uint16, error) {
+	var buf [2]byte
+	err := rd.read(buf[:])
+	return binary.LittleEndian.Uint16(buf[:]), err
+}
+
+func (rd *reader) uint32() (uint32, error) {
+	var buf [4]byte
+	err := rd.read(buf[:])
+	return binary.LittleEndian.Uint32(buf[:]), err
+}
+
+func (rd *reader) uint64() (uint64, error) {
+	var buf [8]byte
+	err := rd.read(buf[:])
+	return binary.LittleEndian.Uint64(buf[:]), err
+}
+
+// count reads a count or length, which version 1 stored as 32 bits
+func (rd *reader) count() (uint64, error) {
+	if rd.version == 1 {
+		n, err := rd.uint32()
+		return uint64(n), err
+	}
+	return rd.uint64()
+}
+
+// countSize returns the encoded size of a count or length
+func (rd *reader) countSize() int64 {
+	if rd.version == 1 {
+		return 4
+	}
+	return 8
+}
+
+// minSize returns the fewest bytes a value of type t takes: strings take
+// at least their length and arrays their element type and length
+func (rd *reader) minSize(t ValueType) int64 {
+	switch t {
+	case TypeString:
+		return rd.countSize()
+	case TypeArray:
+		return 4 + rd.countSize()
+	}
+	if size := t.fixedSize(); size > 0 {
+		return size
+	}
+	return 1
+}
+
+// checkRemaining rejects counts that cannot fit in the rest of the file.
+// Without a known size nothing is preallocated from counts, so reading
+// simply stops at the end of the data.
+func (rd *reader) checkRemaining(count uint64, minSize int64) error {
+	if rd.size <= 0 {
+		return nil
+	}
+	remaining := rd.size - rd.offset
+	if remaining < 0 {
+		remaining = 0
+	}
+	if count > uint64(remaining/minSize) {
+		return fmt.Errorf("length %d at offset %d exceeds file size %d", count, rd.offset, rd.size)
+	}
+	return nil
+}
+
+func (rd *reader) string() (string, error) {
+	length, err := rd.count()
+	if err != nil {
+		return "", err
+	}
+	if length > maxStringLength {
+		return "", fmt.Errorf("string length %d at offset %d is too large", length, rd.offset)
+	}
+	if err := rd.checkRemaining(length, 1); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, length)
+	err = rd.read(buf)
+	return string(buf), err
+}
+
+func (rd *reader) kv() (KV, error) {
+	key, err := rd.string()
+	if err != nil {
+		return KV{}, err
+	}
+
+	valueType, err := rd.uint32()
+	if err != nil {
+		return KV{}, fmt.Errorf("%s: %v", key, err)
+	}
+
+	value, err := rd.value(ValueType(valueType))
+	if err != nil {
+		return KV{}, fmt.Errorf("%s: %v", key, err)
+	}
+
+	return KV{Key: key, Type: ValueType(valueType), Value: value}, nil
+}
+
+// value reads a single metadata value of the given type
+func (rd *reader) value(t ValueType) (interface{}, error) {
+	switch t {
+	case TypeUint8:
+		return rd.uint8()
+	case TypeInt8:
+		v, err := rd.uint8()
+		return int8(v), err
+	case TypeUint16:
+		return rd.uint16()
+	case TypeInt16:
+		v, err := rd.uint16()
+		return int16(v), err
+	case TypeUint32:
+		return rd.uint32()
+	case TypeInt32:
+		v, err := rd.uint32()
+		return int32(v), err
+	case TypeFloat32:
+		v, err := rd.uint32()
+		return math.Float32frombits(v), err
+	case TypeBool:
+		v, err := rd.uint8()
+		return v != 0, err
+	case TypeString:
+		return rd.string()
+	case TypeUint64:
+		return rd.uint64()
+	case TypeInt64:
+		v, err := rd.uint64()
+		return int64(v), err
+	case TypeFloat64:
+		v, err := rd.uint64()
+		return math.Float64frombits(v), err
+	case TypeArray:
+		return rd.array()
+	}
+	return nil, fmt.Errorf("unknown value type %d", uint32(t))
+}
+
+func (rd *reader) array() (*Array, error) {
+	elemType, err := rd.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := rd.count()
+	if err != nil {
+		return nil, err
+	}
+
+	if ValueType(elemType) == TypeArray {
+		if rd.depth >= maxArrayDepth {
+			return nil, fmt.Errorf("arrays nested more than %d deep at offset %d", maxArrayDepth, rd.offset)
+		}
+		rd.depth++
+		defer func() { rd.depth-- }()
+	}
+
+	if err := rd.checkRemaining(length, rd.minSize(ValueType(elemType))); err != nil {
+		return nil, err
+	}
+
+	// The length is only trusted as far as the elements can actually be read
+	array := &Array{Type: ValueType(elemType)}
+	for i := uint64(0); i < length; i++ {
+		value, err := rd.value(ValueType(elemType))
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+		array.Values = append(array.Values, value)
+	}
+
+	return array, nil
+}
+
+func (rd *reader) tensorInfo() (TensorInfo, error) {
+	name, err := rd.string()
+	if err != nil {
+		return TensorInfo{}, err
+	}
+
+	nDims, err := rd.uint32()
+	if err != nil {
+		return TensorInfo{}, fmt.Errorf("%s: %v", name, err)
+	}
+	if nDims > 8 {
+		return TensorInfo{}, fmt.Errorf("%s: invalid dimension count %d", name, nDims)
+	}
+
+	tensor := TensorInfo{Name: name, Dimensions: make([]uint64, nDims)}
+	for i := range tensor.Dimensions {
+		tensor.Dimensions[i], err = rd.count()
+		if err != nil {
+			return TensorInfo{}, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	tensorType, err := rd.uint32()
+	if err != nil {
+		return TensorInfo{}, fmt.Errorf("%s: %v", name, err)
+	}
+	tensor.Type = TensorType(tensorType)
+
+	tensor.Offset, err = rd.uint64()
+	if err != nil {
+		return TensorInfo{}, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return tensor, nil
+}
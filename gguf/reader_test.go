@@ -0,0 +1,243 @@
+package gguf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixture returns a small model header with every scalar type, a string
+// array and a nested array, and one F32 tensor
+func fixture() *File {
+	return &File{
+		Version:   3,
+		Alignment: DefaultAlignment,
+		Metadata: []KV{
+			{Key: "general.architecture", Type: TypeString, Value: "llama"},
+			{Key: "general.name", Type: TypeString, Value: "tiny"},
+			{Key: "test.u8", Type: TypeUint8, Value: uint8(8)},
+			{Key: "test.i8", Type: TypeInt8, Value: int8(-8)},
+			{Key: "test.u16", Type: TypeUint16, Value: uint16(16)},
+			{Key: "test.i16", Type: TypeInt16, Value: int16(-16)},
+			{Key: "llama.context_length", Type: TypeUint32, Value: uint32(4096)},
+			{Key: "test.i32", Type: TypeInt32, Value: int32(-32)},
+			{Key: "test.f32", Type: TypeFloat32, Value: float32(0.5)},
+			{Key: "test.bool", Type: TypeBool, Value: true},
+			{Key: "test.u64", Type: TypeUint64, Value: uint64(1 << 40)},
+			{Key: "test.i64", Type: TypeInt64, Value: int64(-64)},
+			{Key: "test.f64", Type: TypeFloat64, Value: float64(0.25)},
+			{Key: "tokenizer.ggml.tokens", Type: TypeArray, Value: &Array{Type: TypeString, Values: []interface{}{"a", "b", ""}}},
+			{Key: "test.nested", Type: TypeArray, Value: &Array{Type: TypeArray, Values: []interface{}{
+				&Array{Type: TypeUint32, Values: []interface{}{uint32(1), uint32(2)}},
+				&Array{Type: TypeUint32},
+			}}},
+		},
+		Tensors: []TensorInfo{
+			{Name: "token_embd.weight", Dimensions: []uint64{4, 2}, Type: 0, Offset: 0},
+		},
+	}
+}
+
+// writeFixture writes f with data as its tensor data and returns the path
+func writeFixture(t *testing.T, f *File, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := f.writeTo(out, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := fixture()
+	data := make([]byte, 32)
+	path := writeFixture(t, want, data)
+
+	got, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !reflect.DeepEqual(got.Metadata, want.Metadata) {
+		t.Errorf("metadata = %#v, want %#v", got.Metadata, want.Metadata)
+	}
+	if !reflect.DeepEqual(got.Tensors, want.Tensors) {
+		t.Errorf("tensors = %#v, want %#v", got.Tensors, want.Tensors)
+	}
+	if got.DataOffset%DefaultAlignment != 0 || got.DataOffset+int64(len(data)) != got.FileSize {
+		t.Errorf("data offset %d, file size %d", got.DataOffset, got.FileSize)
+	}
+	if got.Architecture() != "llama" {
+		t.Errorf("architecture = %q", got.Architecture())
+	}
+
+	// Editing and rewriting keeps everything else
+	if err := got.Set("general.name", TypeString, "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Remove("test.i8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Set("general.alignment", TypeUint32, uint32(64)); err == nil {
+		t.Error("Set of general.alignment succeeded")
+	}
+	if err := got.Rewrite(path); err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+
+	rewritten, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open after Rewrite: %v", err)
+	}
+	if rewritten.GetString("general.name") != "renamed" {
+		t.Errorf("general.name = %q", rewritten.GetString("general.name"))
+	}
+	if _, ok := rewritten.Get("test.i8"); ok {
+		t.Error("test.i8 still present")
+	}
+	if len(rewritten.Metadata) != len(want.Metadata)-1 || !reflect.DeepEqual(rewritten.Tensors, want.Tensors) {
+		t.Errorf("rewrite changed other entries: %d metadata, tensors %v", len(rewritten.Metadata), rewritten.Tensors)
+	}
+	if rewritten.FileSize-rewritten.DataOffset != int64(len(data)) {
+		t.Errorf("data section is %d bytes, want %d", rewritten.FileSize-rewritten.DataOffset, len(data))
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	path := writeFixture(t, fixture(), nil)
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := int64(0); n < header.HeaderSize; n++ {
+		_, err := Read(bytes.NewReader(full[:n]), n)
+		if err == nil {
+			t.Fatalf("Read of the first %d bytes succeeded", n)
+		}
+	}
+}
+
+// header builds a raw GGUF header: magic, version and the given fields,
+// each a uint32, uint64, string or []byte
+func header(version uint32, fields ...interface{}) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(Magic))
+	binary.Write(&buf, binary.LittleEndian, version)
+	for _, field := range fields {
+		switch v := field.(type) {
+		case string:
+			binary.Write(&buf, binary.LittleEndian, uint64(len(v)))
+			buf.WriteString(v)
+		case []byte:
+			buf.Write(v)
+		default:
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestMalicious(t *testing.T) {
+	// Arrays nested one level deeper than the reader allows
+	nested := []interface{}{uint64(0), uint64(1), "k", uint32(TypeArray)}
+	for i := 0; i < maxArrayDepth+1; i++ {
+		nested = append(nested, uint32(TypeArray), uint64(1))
+	}
+	nested = append(nested, uint32(TypeUint8), uint64(0))
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"bad magic", []byte("GGML\x03\x00\x00\x00"), "not a GGUF file"},
+		{"bad version", header(4, uint64(0), uint64(0)), "unsupported GGUF version"},
+		{"huge metadata count", header(3, uint64(0), uint64(1<<62)), "exceeds file size"},
+		{"huge tensor count", header(3, uint64(1<<62), uint64(0)), "exceeds file size"},
+		{"huge key length", header(3, uint64(0), uint64(1), uint64(1<<60), make([]byte, 64)), "too large"},
+		{"key past end of file", header(3, uint64(0), uint64(1), uint64(1000), "x"), "exceeds file size"},
+		{"unknown value type", header(3, uint64(0), uint64(1), "k", uint32(99)), "unknown value type"},
+		{"huge array of arrays", header(3, uint64(0), uint64(1), "k", uint32(TypeArray), uint32(TypeArray), uint64(1<<60)), "exceeds file size"},
+		{"huge array of strings", header(3, uint64(0), uint64(1), "k", uint32(TypeArray), uint32(TypeString), uint64(1<<60)), "exceeds file size"},
+		{"huge array of uint64", header(3, uint64(0), uint64(1), "k", uint32(TypeArray), uint32(TypeUint64), uint64(1<<61)), "exceeds file size"},
+		{"array of unknown type", header(3, uint64(0), uint64(1), "k", uint32(TypeArray), uint32(99), uint64(1), []byte{0}), "unknown value type"},
+		{"deeply nested arrays", header(3, nested...), "nested"},
+		{"too many dimensions", header(3, uint64(1), uint64(0), "t", uint32(9), make([]byte, 64)), "invalid dimension count"},
+		{"bad alignment", header(3, uint64(0), uint64(1), "general.alignment", uint32(TypeUint32), uint32(3)), "invalid general.alignment"},
+		{"version 1 huge array", header(1, uint32(0), uint32(1), []byte{1, 0, 0, 0, 'k'}, uint32(TypeArray), uint32(TypeArray), uint32(1<<31)), "exceeds file size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+
+			// Without a known size, reading must still fail cleanly
+			_, err = Read(bytes.NewReader(tt.data), 0)
+			if err == nil {
+				t.Fatal("succeeded with an unknown size")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const dataOffset = 64
+	f32 := func(name string, offset uint64, dims ...uint64) TensorInfo {
+		return TensorInfo{Name: name, Dimensions: dims, Type: 0, Offset: offset}
+	}
+
+	tests := []struct {
+		name      string
+		tensors   []TensorInfo
+		data      int64
+		want      []string
+		truncated bool
+	}{
+		{"sound", []TensorInfo{f32("a", 0, 8), f32("b", 32, 8)}, 64, nil, false},
+		{"truncated", []TensorInfo{f32("a", 0, 8), f32("b", 32, 8)}, 40, []string{"tensor b (32 bytes at offset 32) does not fit"}, true},
+		{"no data at all", []TensorInfo{f32("a", 0, 8)}, 0, []string{"tensor a "}, true},
+		{"overlap", []TensorInfo{f32("a", 0, 16), f32("b", 32, 8)}, 64, []string{"tensors a and b overlap"}, false},
+		{"overlap past the next tensor", []TensorInfo{f32("a", 0, 32), f32("b", 32, 8), f32("c", 64, 8)}, 128, []string{"tensors a and b overlap", "tensors a and c overlap"}, false},
+		{"wrapped offset", []TensorInfo{f32("a", 0, 8), f32("b", math.MaxUint64-31, 8)}, 64, []string{"tensor b (32 bytes at offset 18446744073709551584) does not fit"}, true},
+		{"overflowing dimensions", []TensorInfo{f32("a", 0, 8), f32("b", 32, 1<<40, 1<<40)}, 64, []string{"tensor b "}, true},
+		{"trailing bytes", []TensorInfo{f32("a", 0, 8)}, 96, []string{"64 unexpected bytes after the last tensor"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &File{Alignment: DefaultAlignment, DataOffset: dataOffset, FileSize: dataOffset + tt.data, Tensors: tt.tensors}
+			problems := f.Check()
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %d", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+			if f.Truncated() != tt.truncated {
+				t.Errorf("Truncated() = %v, want %v", f.Truncated(), tt.truncated)
+			}
+			if f.Truncated() != (f.ExpectedSize() > f.FileSize) {
+				t.Errorf("ExpectedSize() = %d for a %d byte file", f.ExpectedSize(), f.FileSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package gguf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Metadata keys written by llama-gguf-split
+const (
+	KeySplitNo           = "split.no"
+	KeySplitCount        = "split.count"
+	KeySplitTensorsCount = "split.tensors.count"
+)
+
+// shardPattern matches names like "model-00001-of-00003.gguf"
+var shardPattern = regexp.MustCompile(`^(.*)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// Model is a GGUF model made of one or more files
+type Model struct {
+	Shards []*File
+}
+
+// ShardInfo is the position of a file in a split set, parsed from its name
+type ShardInfo struct {
+	Prefix string
+	Index  int // 1-based
+	Count  int
+}
+
+// ParseShardName reports whether path is named like a split shard
+func ParseShardName(path string) (ShardInfo, bool) {
+	match := shardPattern.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		return ShardInfo{}, false
+	}
+
+	index, _ := strconv.Atoi(match[2])
+	count, _ := strconv.Atoi(match[3])
+	if index < 1 || count < 1 || index > count {
+		return ShardInfo{}, false
+	}
+
+	return ShardInfo{
+		Prefix: filepath.Join(filepath.Dir(path), match[1]),
+		Index:  index,
+		Count:  count,
+	}, true
+}
+
+// ShardPath returns the path of shard index (1-based) of count
+func ShardPath(prefix string, index, count int) string {
+	return fmt.Sprintf("%s-%05d-of-%05d.gguf", prefix, index, count)
+}
+
+// ShardPaths returns every shard path in the set containing path, or just
+// path when it is not a split file
+func ShardPaths(path string) []string {
+	shard, ok := ParseShardName(path)
+	if !ok {
+		return []string{path}
+	}
+
+	paths := make([]string, 0, shard.Count)
+	for i := 1; i <= shard.Count; i++ {
+		paths = append(paths, ShardPath(shard.Prefix, i, shard.Count))
+	}
+	return paths
+}
+
+// OpenModel opens path and, if it belongs to a split set, all other shards
+func OpenModel(path string) (*Model, error) {
+	first, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	count, _ := first.GetUint(KeySplitCount)
+	if count <= 1 {
+		return &Model{Shards: []*File{first}}, nil
+	}
+
+	paths := ShardPaths(path)
+	if len(paths) != int(count) {
+		return nil, fmt.Errorf("%s: split.count is %d but the file name does not follow the -NNNNN-of-NNNNN.gguf pattern", path, count)
+	}
+
+	model := &Model{}
+	for i, shardPath := range paths {
+		var shard *File
+		if shardPath == path {
+			shard = first
+		} else {
+			shard, err = Open(shardPath)
+			if err != nil {
+				return nil, fmt.Errorf("shard %d of %d: %v", i+1, count, err)
+			}
+		}
+		model.Shards = append(model.Shards, shard)
+	}
+
+	return model, nil
+}
+
+// Metadata returns the first shard, which carries the model metadata
+func (m *Model) Metadata() *File {
+	return m.Shards[0]
+}
+
+// Tensors returns the tensor tables of all shards combined
+func (m *Model) Tensors() []TensorInfo {
+	var tensors []TensorInfo
+	for _, shard := range m.Shards {
+		tensors = append(tensors, shard.Tensors...)
+	}
+	return tensors
+}
+
+// ParameterCount returns the total number of tensor elements
+func (m *Model) ParameterCount() uint64 {
+	var count uint64
+	for _, tensor := range m.Tensors() {
+		count += tensor.Elements()
+	}
+	return count
+}
+
+// TensorDataSize returns the total size of all tensor data in bytes
+func (m *Model) TensorDataSize() uint64 {
+	var size uint64
+	for _, tensor := range m.Tensors() {
+		size += tensor.Size()
+	}
+	return size
+}
+
+// FileSize returns the combined size of all shards on disk
+func (m *Model) FileSize() int64 {
+	var size int64
+	for _, shard := range m.Shards {
+		size += shard.FileSize
+	}
+	return size
+}
+
+// Validate checks that the shards of a split model agree on their position,
+// count and total tensor count
+func (m *Model) Validate() error {
+	if len(m.Shards) <= 1 {
+		return nil
+	}
+
+	count := uint64(len(m.Shards))
+	for i, shard := range m.Shards {
+		no, ok := shard.GetUint(KeySplitNo)
+		if !ok {
+			return fmt.Errorf("%s: missing %s", shard.Path, KeySplitNo)
+		}
+		if no != uint64(i) {
+			return fmt.Errorf("%s: %s is %d, expected %d", shard.Path, KeySplitNo, no, i)
+		}
+
+		shardCount, _ := shard.GetUint(KeySplitCount)
+		if shardCount != count {
+			return fmt.Errorf("%s: %s is %d, expected %d", shard.Path, KeySplitCount, shardCount, count)
+		}
+	}
+
+	tensors, ok := m.Shards[0].GetUint(KeySplitTensorsCount)
+	if ok && tensors != uint64(len(m.Tensors())) {
+		return fmt.Errorf("shards hold %d tensors but %s is %d", len(m.Tensors()), KeySplitTensorsCount, tensors)
+	}
+	return nil
+}
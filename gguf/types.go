@@ -0,0 +1,193 @@
+package gguf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValueType identifies the type of a metadata value
+type ValueType uint32
+
+// Metadata value types as defined by the GGUF specification
+const (
+	TypeUint8   ValueType = 0
+	TypeInt8    ValueType = 1
+	TypeUint16  ValueType = 2
+	TypeInt16   ValueType = 3
+	TypeUint32  ValueType = 4
+	TypeInt32   ValueType = 5
+	TypeFloat32 ValueType = 6
+	TypeBool    ValueType = 7
+	TypeString  ValueType = 8
+	TypeArray   ValueType = 9
+	TypeUint64  ValueType = 10
+	TypeInt64   ValueType = 11
+	TypeFloat64 ValueType = 12
+)
+
+// valueTypeNames maps value types to their names for display
+var valueTypeNames = map[ValueType]string{
+	TypeUint8:   "uint8",
+	TypeInt8:    "int8",
+	TypeUint16:  "uint16",
+	TypeInt16:   "int16",
+	TypeUint32:  "uint32",
+	TypeInt32:   "int32",
+	TypeFloat32: "float32",
+	TypeBool:    "bool",
+	TypeString:  "string",
+	TypeArray:   "array",
+	TypeUint64:  "uint64",
+	TypeInt64:   "int64",
+	TypeFloat64: "float64",
+}
+
+// String returns the name of the value type
+func (t ValueType) String() string {
+	if name, ok := valueTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", uint32(t))
+}
+
+// fixedSize returns the encoded size of a scalar value type, or 0 for
+// variable-length types
+func (t ValueType) fixedSize() int64 {
+	switch t {
+	case TypeUint8, TypeInt8, TypeBool:
+		return 1
+	case TypeUint16, TypeInt16:
+		return 2
+	case TypeUint32, TypeInt32, TypeFloat32:
+		return 4
+	case TypeUint64, TypeInt64, TypeFloat64:
+		return 8
+	}
+	return 0
+}
+
+// TensorType identifies the ggml storage type of a tensor
+type TensorType uint32
+
+// tensorTypeInfo describes how a ggml type packs elements into blocks
+type tensorTypeInfo struct {
+	name      string
+	blockSize int64 // elements per block
+	typeSize  int64 // bytes per block
+}
+
+// tensorTypes lists the ggml types llama.cpp writes to GGUF files
+var tensorTypes = map[TensorType]tensorTypeInfo{
+	0:  {"F32", 1, 4},
+	1:  {"F16", 1, 2},
+	2:  {"Q4_0", 32, 18},
+	3:  {"Q4_1", 32, 20},
+	6:  {"Q5_0", 32, 22},
+	7:  {"Q5_1", 32, 24},
+	8:  {"Q8_0", 32, 34},
+	9:  {"Q8_1", 32, 36},
+	10: {"Q2_K", 256, 84},
+	11: {"Q3_K", 256, 110},
+	12: {"Q4_K", 256, 144},
+	13: {"Q5_K", 256, 176},
+	14: {"Q6_K", 256, 210},
+	15: {"Q8_K", 256, 292},
+	16: {"IQ2_XXS", 256, 66},
+	17: {"IQ2_XS", 256, 74},
+	18: {"IQ3_XXS", 256, 98},
+	19: {"IQ1_S", 256, 50},
+	20: {"IQ4_NL", 32, 18},
+	21: {"IQ3_S", 256, 110},
+	22: {"IQ2_S", 256, 82},
+	23: {"IQ4_XS", 256, 136},
+	24: {"I8", 1, 1},
+	25: {"I16", 1, 2},
+	26: {"I32", 1, 4},
+	27: {"I64", 1, 8},
+	28: {"F64", 1, 8},
+	29: {"IQ1_M", 256, 56},
+	30: {"BF16", 1, 2},
+	34: {"TQ1_0", 256, 54},
+	35: {"TQ2_0", 256, 66},
+	39: {"MXFP4", 32, 17},
+}
+
+// String returns the ggml name of the tensor type
+func (t TensorType) String() string {
+	if info, ok := tensorTypes[t]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("type(%d)", uint32(t))
+}
+
+// Known reports whether the tensor type's block layout is known
+func (t TensorType) Known() bool {
+	_, ok := tensorTypes[t]
+	return ok
+}
+
+// BytesPerElement returns the average storage cost of one element
+func (t TensorType) BytesPerElement() float64 {
+	info, ok := tensorTypes[t]
+	if !ok {
+		return 0
+	}
+	return float64(info.typeSize) / float64(info.blockSize)
+}
+
+// fileTypeNames maps general.file_type values to llama.cpp's quantization
+// names
+var fileTypeNames = map[uint64]string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	7:  "Q8_0",
+	8:  "Q5_0",
+	9:  "Q5_1",
+	10: "Q2_K",
+	11: "Q3_K_S",
+	12: "Q3_K_M",
+	13: "Q3_K_L",
+	14: "Q4_K_S",
+	15: "Q4_K_M",
+	16: "Q5_K_S",
+	17: "Q5_K_M",
+	18: "Q6_K",
+	19: "IQ2_XXS",
+	20: "IQ2_XS",
+	21: "Q2_K_S",
+	22: "IQ3_XS",
+	23: "IQ3_XXS",
+	24: "IQ1_S",
+	25: "IQ4_NL",
+	26: "IQ3_S",
+	27: "IQ3_M",
+	28: "IQ2_S",
+	29: "IQ2_M",
+	30: "IQ4_XS",
+	31: "IQ1_M",
+	32: "BF16",
+	36: "TQ1_0",
+	37: "TQ2_0",
+	38: "MXFP4_MOE",
+}
+
+// FileTypeName returns the quantization name for a general.file_type value
+func FileTypeName(fileType uint64) string {
+	if name, ok := fileTypeNames[fileType]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", fileType)
+}
+
+// ParseFileTypeName returns the canonical quantization name for name, e.g.
+// "Q4_K_M" for "q4_k_m", or false if llama.cpp has no such file type
+func ParseFileTypeName(name string) (string, bool) {
+	for _, known := range fileTypeNames {
+		if strings.EqualFold(known, name) {
+			return known, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,103 @@
+package gguf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ExpectedSize returns the file size implied by the tensor table: the end of
+// the last tensor's data, or math.MaxInt64 when that lies beyond any file
+func (f *File) ExpectedSize() int64 {
+	end := uint64(0)
+	for _, tensor := range f.Tensors {
+		end = max(end, tensor.end())
+	}
+	if end > uint64(math.MaxInt64-f.DataOffset) {
+		return math.MaxInt64
+	}
+	return f.DataOffset + int64(end)
+}
+
+// end returns the offset just past the tensor's data, saturating at
+// math.MaxUint64 rather than wrapping
+func (t TensorInfo) end() uint64 {
+	size := t.Size()
+	if t.Offset > math.MaxUint64-size {
+		return math.MaxUint64
+	}
+	return t.Offset + size
+}
+
+// inFile reports whether the tensor's data lies within the file, without
+// letting the offset or size wrap around
+func (f *File) inFile(tensor TensorInfo) bool {
+	if f.FileSize < f.DataOffset {
+		return false
+	}
+	avail := uint64(f.FileSize - f.DataOffset)
+	return tensor.Offset <= avail && tensor.Size() <= avail-tensor.Offset
+}
+
+// Truncated reports whether the file is shorter than its tensor table needs
+func (f *File) Truncated() bool {
+	for _, tensor := range f.Tensors {
+		if !f.inFile(tensor) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates the tensor table against the file: known types, aligned
+// offsets, no overlaps or duplicates, and all data within the file
+func (f *File) Check() []string {
+	var problems []string
+
+	seen := map[string]bool{}
+	for _, tensor := range f.Tensors {
+		if seen[tensor.Name] {
+			problems = append(problems, fmt.Sprintf("duplicate tensor %s", tensor.Name))
+		}
+		seen[tensor.Name] = true
+
+		if !f.inFile(tensor) {
+			problems = append(problems, fmt.Sprintf("tensor %s (%d bytes at offset %d) does not fit in the %d bytes of tensor data; the file is truncated or the offset is invalid",
+				tensor.Name, tensor.Size(), tensor.Offset, max(f.FileSize-f.DataOffset, 0)))
+		}
+		if !tensor.Type.Known() {
+			problems = append(problems, fmt.Sprintf("tensor %s has unknown type %d", tensor.Name, uint32(tensor.Type)))
+			continue
+		}
+		if tensor.Offset%f.Alignment != 0 {
+			problems = append(problems, fmt.Sprintf("tensor %s offset %d is not aligned to %d", tensor.Name, tensor.Offset, f.Alignment))
+		}
+		if info := tensorTypes[tensor.Type]; len(tensor.Dimensions) > 0 && tensor.Dimensions[0]%uint64(info.blockSize) != 0 {
+			problems = append(problems, fmt.Sprintf("tensor %s row size %d is not a multiple of the %s block size %d", tensor.Name, tensor.Dimensions[0], tensor.Type, info.blockSize))
+		}
+	}
+
+	// Tensor data must not overlap. Compare each tensor against the one
+	// reaching furthest so far, using distances so nothing wraps
+	sorted := make([]TensorInfo, len(f.Tensors))
+	copy(sorted, f.Tensors)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+	for i, furthest := 1, 0; i < len(sorted); i++ {
+		prev, next := sorted[furthest], sorted[i]
+		if prev.Size() > next.Offset-prev.Offset {
+			problems = append(problems, fmt.Sprintf("tensors %s and %s overlap", prev.Name, next.Name))
+		}
+		if next.end() > prev.end() {
+			furthest = i
+		}
+	}
+
+	// Anything beyond the last tensor and its padding is unexpected
+	if !f.Truncated() {
+		if limit := AlignOffset(f.ExpectedSize(), f.Alignment); f.FileSize > limit {
+			problems = append(problems, fmt.Sprintf("%d unexpected bytes after the last tensor", f.FileSize-limit))
+		}
+	}
+
+	return problems
+}
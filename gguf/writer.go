@@ -0,0 +1,296 @@
+package gguf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeVersion is the GGUF version written by Rewrite; older files are
+// upgraded since version 3 only widened counts and lengths
+const writeVersion = 3
+
+// protectedKeys can't be edited because tensor data layout depends on them
+var protectedKeys = map[string]bool{
+	"general.alignment":  true,
+	KeySplitNo:           true,
+	KeySplitCount:        true,
+	KeySplitTensorsCount: true,
+}
+
+// ParseValueType returns the value type with the given name, e.g. "uint32"
+func ParseValueType(name string) (ValueType, bool) {
+	for t, known := range valueTypeNames {
+		if known == name && t != TypeArray {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ParseValue converts text to a scalar metadata value of type t
+func ParseValue(t ValueType, text string) (interface{}, error) {
+	switch t {
+	case TypeString:
+		return text, nil
+	case TypeBool:
+		return strconv.ParseBool(text)
+	case TypeFloat32:
+		v, err := strconv.ParseFloat(text, 32)
+		return float32(v), err
+	case TypeFloat64:
+		return strconv.ParseFloat(text, 64)
+	}
+
+	size := int(t.fixedSize()) * 8
+	switch t {
+	case TypeUint8, TypeUint16, TypeUint32, TypeUint64:
+		v, err := strconv.ParseUint(text, 10, size)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case TypeUint8:
+			return uint8(v), nil
+		case TypeUint16:
+			return uint16(v), nil
+		case TypeUint32:
+			return uint32(v), nil
+		}
+		return v, nil
+	case TypeInt8, TypeInt16, TypeInt32, TypeInt64:
+		v, err := strconv.ParseInt(text, 10, size)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case TypeInt8:
+			return int8(v), nil
+		case TypeInt16:
+			return int16(v), nil
+		case TypeInt32:
+			return int32(v), nil
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("cannot set values of type %s", t)
+}
+
+// InferValueType guesses the type of a new key from its text: booleans,
+// integers and decimals are stored as such, anything else as a string
+func InferValueType(text string) ValueType {
+	if text == "true" || text == "false" {
+		return TypeBool
+	}
+	if _, err := strconv.ParseUint(text, 10, 32); err == nil {
+		return TypeUint32
+	}
+	if _, err := strconv.ParseInt(text, 10, 32); err == nil {
+		return TypeInt32
+	}
+	if _, err := strconv.ParseFloat(text, 32); err == nil && strings.ContainsAny(text, ".eE") {
+		return TypeFloat32
+	}
+	return TypeString
+}
+
+// Set adds or replaces a metadata value, keeping the position of an existing
+// key
+func (f *File) Set(key string, t ValueType, value interface{}) error {
+	if protectedKeys[key] {
+		return fmt.Errorf("%s can't be changed without rewriting tensor data", key)
+	}
+
+	for i := range f.Metadata {
+		if f.Metadata[i].Key == key {
+			f.Metadata[i].Type = t
+			f.Metadata[i].Value = value
+			return nil
+		}
+	}
+	f.Metadata = append(f.Metadata, KV{Key: key, Type: t, Value: value})
+	return nil
+}
+
+// Remove deletes a metadata key
+func (f *File) Remove(key string) error {
+	if protectedKeys[key] || key == "general.architecture" {
+		return fmt.Errorf("%s can't be removed", key)
+	}
+
+	for i := range f.Metadata {
+		if f.Metadata[i].Key == key {
+			f.Metadata = append(f.Metadata[:i], f.Metadata[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("key %s not found", key)
+}
+
+// Rewrite writes f's header with its current metadata to dest, followed by
+// the tensor data copied unchanged from f.Path. dest is written through a
+// temporary file so it is never left half-written.
+func (f *File) Rewrite(dest string) error {
+	src, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = f.writeTo(tmp, io.NewSectionReader(src, f.DataOffset, f.FileSize-f.DataOffset))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(f.Path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// writeTo writes the header, alignment padding and tensor data
+func (f *File) writeTo(out *os.File, data io.Reader) error {
+	w := &writer{w: bufio.NewWriterSize(out, 1<<20)}
+
+	w.uint32(Magic)
+	w.uint32(writeVersion)
+	w.uint64(uint64(len(f.Tensors)))
+	w.uint64(uint64(len(f.Metadata)))
+
+	for _, kv := range f.Metadata {
+		w.string(kv.Key)
+		w.uint32(uint32(kv.Type))
+		w.value(kv.Type, kv.Value)
+	}
+
+	for _, tensor := range f.Tensors {
+		w.string(tensor.Name)
+		w.uint32(uint32(len(tensor.Dimensions)))
+		for _, dim := range tensor.Dimensions {
+			w.uint64(dim)
+		}
+		w.uint32(uint32(tensor.Type))
+		w.uint64(tensor.Offset)
+	}
+
+	// The data section starts at the next aligned offset
+	padding := AlignOffset(w.offset, f.Alignment) - w.offset
+	w.write(make([]byte, padding))
+	if w.err != nil {
+		return w.err
+	}
+
+	err := w.w.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, data)
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// writer encodes little-endian GGUF primitives, keeping the first error
+type writer struct {
+	w      *bufio.Writer
+	offset int64
+	err    error
+}
+
+func (w *writer) write(buf []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(buf)
+	w.offset += int64(n)
+	w.err = err
+}
+
+func (w *writer) uint8(v uint8) {
+	w.write([]byte{v})
+}
+
+func (w *writer) uint16(v uint16) {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	w.write(buf[:])
+}
+
+func (w *writer) uint32(v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	w.write(buf[:])
+}
+
+func (w *writer) uint64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	w.write(buf[:])
+}
+
+func (w *writer) string(s string) {
+	w.uint64(uint64(len(s)))
+	w.write([]byte(s))
+}
+
+// value writes a metadata value as decoded by reader.value
+func (w *writer) value(t ValueType, value interface{}) {
+	switch v := value.(type) {
+	case uint8:
+		w.uint8(v)
+	case int8:
+		w.uint8(uint8(v))
+	case uint16:
+		w.uint16(v)
+	case int16:
+		w.uint16(uint16(v))
+	case uint32:
+		w.uint32(v)
+	case int32:
+		w.uint32(uint32(v))
+	case float32:
+		w.uint32(math.Float32bits(v))
+	case bool:
+		if v {
+			w.uint8(1)
+		} else {
+			w.uint8(0)
+		}
+	case string:
+		w.string(v)
+	case uint64:
+		w.uint64(v)
+	case int64:
+		w.uint64(uint64(v))
+	case float64:
+		w.uint64(math.Float64bits(v))
+	case *Array:
+		w.uint32(uint32(v.Type))
+		w.uint64(uint64(len(v.Values)))
+		for _, elem := range v.Values {
+			w.value(v.Type, elem)
+		}
+	default:
+		if w.err == nil {
+			w.err = fmt.Errorf("cannot encode %T as %s", value, t)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package hub lists and resolves model files on Hugging Face-compatible hubs.
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/utils"
+)
+
+// DefaultRevision is used when a pull doesn't name a branch or commit
+const DefaultRevision = "main"
+
+// TokenEnv is the environment variable holding the hub access token
+const TokenEnv = "HF_TOKEN"
+
+// Client talks to a hub's HTTP API
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// File is one file in a model repository
+type File struct {
+	Path string
+	Size int64
+
+	// SHA256 comes from the LFS pointer; empty for files not stored in LFS
+	SHA256 string
+}
+
+// treeEntry is an element of the tree API response
+type treeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	} `json:"lfs"`
+}
+
+// NewClient returns a client for the configured hub_url
+func NewClient(ctx *utils.Context) *Client {
+	baseURL := ctx.Settings.HubURL
+	if baseURL == "" {
+		baseURL = utils.DefaultHubURL
+	}
+
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   os.Getenv(TokenEnv),
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// Header returns the headers to send with every request
+func (c *Client) Header() http.Header {
+	header := http.Header{}
+	if c.Token != "" {
+		header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return header
+}
+
+// ListFiles returns every file in repo at revision
+func (c *Client) ListFiles(repo, revision string) ([]File, error) {
+	next := fmt.Sprintf("%s/api/models/%s/tree/%s?recursive=true", c.BaseURL, repo, url.PathEscape(revision))
+
+	var files []File
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = c.Header()
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []treeEntry
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&entries)
+		} else {
+			err = c.statusError(&utils.StatusError{URL: next, StatusCode: resp.StatusCode, Status: resp.Status}, repo)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.Type != "file" {
+				continue
+			}
+			file := File{Path: entry.Path, Size: entry.Size}
+			if entry.LFS != nil {
+				file.SHA256 = entry.LFS.OID
+				file.Size = entry.LFS.Size
+			}
+			files = append(files, file)
+		}
+
+		// Large repositories are paginated with a Link header
+		next = nextPageURL(resp.Header.Get("Link"))
+	}
+
+	return files, nil
+}
+
+// FileURL returns the download URL of a file
+func (c *Client) FileURL(repo, revision, filePath string) string {
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", c.BaseURL, repo, url.PathEscape(revision), filePath)
+}
+
+// Download fetches file into dest, resuming and verifying it
+func (c *Client) Download(repo, revision string, file File, dest string, connections int) error {
+	err := utils.DownloadFile(c.FileURL(repo, revision, file.Path), dest, utils.DownloadOptions{
+		Size:        file.Size,
+		SHA256:      file.SHA256,
+		Connections: connections,
+		Header:      c.Header(),
+		Client:      c.HTTP,
+	})
+	return c.statusError(err, repo)
+}
+
+// statusError adds a hint to authorization and not-found errors
+func (c *Client) statusError(err error, repo string) error {
+	var status *utils.StatusError
+	if !errors.As(err, &status) {
+		return err
+	}
+
+	switch status.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if c.Token == "" {
+			return fmt.Errorf("%v (%s may be gated or private; set %s to an access token)", err, repo, TokenEnv)
+		}
+		return fmt.Errorf("%v (check that %s grants access to %s)", err, TokenEnv, repo)
+	case http.StatusNotFound:
+		return fmt.Errorf("%v (check the repository name and revision)", err)
+	}
+	return err
+}
+
+// nextPageURL extracts the rel="next" target from a Link header
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, found := strings.Cut(strings.TrimSpace(part), ";")
+		if found && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// ParseRef splits "<repo>[:<file or quant>]"
+func ParseRef(ref string) (repo, selector string, err error) {
+	repo, selector, _ = strings.Cut(ref, ":")
+	if strings.Count(repo, "/") != 1 || strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") {
+		return "", "", fmt.Errorf("invalid repository %q, expected <owner>/<name>", repo)
+	}
+	return repo, selector, nil
+}
+
+// Resolve picks the files to download for selector, which is an exact path,
+// a quantization such as "Q4_K_M", or empty when the repository holds a
+// single GGUF model. A quantization must appear in the file name between
+// "-" or "." separators, so "Q2_K" doesn't also match "Q2_K_S". Every part
+// of a split model is included.
+func Resolve(files []File, selector string) ([]File, error) {
+	byPath := map[string]File{}
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+
+	var candidates []File
+	if file, ok := byPath[selector]; ok && selector != "" {
+		candidates = []File{file}
+	} else {
+		needle := strings.ToLower(selector)
+		for _, file := range files {
+			name := strings.ToLower(path.Base(file.Path))
+			if !strings.HasSuffix(name, ".gguf") {
+				continue
+			}
+			// Projectors are pulled by naming them explicitly
+			if needle == "" && strings.Contains(name, "mmproj") {
+				continue
+			}
+			if containsToken(name, needle) {
+				candidates = append(candidates, file)
+			}
+		}
+	}
+
+	// Group split parts so a set counts as one candidate
+	sets := map[string][]File{}
+	var order []string
+	for _, file := range candidates {
+		key := file.Path
+		if shard, ok := gguf.ParseShardName(file.Path); ok {
+			key = shard.Prefix
+		}
+		if _, ok := sets[key]; !ok {
+			order = append(order, key)
+		}
+		sets[key] = append(sets[key], file)
+	}
+
+	switch len(order) {
+	case 0:
+		if selector == "" {
+			return nil, fmt.Errorf("no GGUF files found")
+		}
+		return nil, fmt.Errorf("no GGUF file matches %q", selector)
+	case 1:
+		return expandShards(sets[order[0]][0], byPath)
+	}
+
+	sort.Strings(order)
+	var names []string
+	for _, key := range order {
+		names = append(names, "  "+sets[key][0].Path)
+	}
+	return nil, fmt.Errorf("several files match, pick one with <repo>:<file or quant>:\n%s", strings.Join(names, "\n"))
+}
+
+// containsToken reports whether needle occurs in name bounded on both sides
+// by "-", "." or the ends of name. An empty needle matches everything.
+func containsToken(name, needle string) bool {
+	if needle == "" {
+		return true
+	}
+	for i := 0; i+len(needle) <= len(name); i++ {
+		j := i + len(needle)
+		if name[i:j] == needle && (i == 0 || isSeparator(name[i-1])) && (j == len(name) || isSeparator(name[j])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSeparator reports whether c separates the parts of a model file name
+func isSeparator(c byte) bool {
+	return c == '-' || c == '.'
+}
+
+// expandShards returns every part of the split set containing file
+func expandShards(file File, byPath map[string]File) ([]File, error) {
+	paths := gguf.ShardPaths(file.Path)
+
+	result := make([]File, 0, len(paths))
+	for _, shardPath := range paths {
+		shard, ok := byPath[shardPath]
+		if !ok {
+			return nil, fmt.Errorf("%s is part of a split model but %s is missing from the repository", file.Path, shardPath)
+		}
+		result = append(result, shard)
+	}
+	return result, nil
+}
@@ -0,0 +1,154 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListFilesPagination(t *testing.T) {
+	pages := [][]treeEntry{
+		{
+			{Type: "directory", Path: "Q8_0"},
+			{Type: "file", Path: "README.md", Size: 10},
+		},
+		{
+			{Type: "file", Path: "model-Q4_K_M.gguf", Size: 5, LFS: &struct {
+				OID  string `json:"oid"`
+				Size int64  `json:"size"`
+			}{OID: "abc123", Size: 4 << 30}},
+		},
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/models/owner/model/tree/main" || r.URL.Query().Get("recursive") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		page := 0
+		fmt.Sscan(r.URL.Query().Get("cursor"), &page)
+		if page+1 < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?recursive=true&cursor=%d>; rel="next"`, server.URL, r.URL.Path, page+1))
+		}
+		json.NewEncoder(w).Encode(pages[page])
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, Token: "secret", HTTP: server.Client()}
+	files, err := client.ListFiles("owner/model", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []File{
+		{Path: "README.md", Size: 10},
+		{Path: "model-Q4_K_M.gguf", Size: 4 << 30, SHA256: "abc123"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %+v, want %+v", files, want)
+	}
+
+	// Errors carry a hint about the token
+	client.Token = ""
+	_, err = client.ListFiles("owner/model", "main")
+	if err == nil || !strings.Contains(err.Error(), TokenEnv) {
+		t.Errorf("error without a token = %v, want a hint to set %s", err, TokenEnv)
+	}
+	_, err = client.ListFiles("owner/missing", "main")
+	if err == nil || !strings.Contains(err.Error(), "check the repository name") {
+		t.Errorf("error for a missing repository = %v", err)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{`<https://hub/a?cursor=1>; rel="next"`, "https://hub/a?cursor=1"},
+		{`<https://hub/a?cursor=0>; rel="prev", <https://hub/a?cursor=2>; rel="next"`, "https://hub/a?cursor=2"},
+		{`<https://hub/a?cursor=0>; rel="prev"`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := nextPageURL(tt.link); got != tt.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	files := []File{
+		{Path: "README.md"},
+		{Path: "model-Q2_K.gguf"},
+		{Path: "model-Q2_K_S.gguf"},
+		{Path: "model-Q2_K_L.gguf"},
+		{Path: "model.Q4_K_M.gguf"},
+		{Path: "model-Q4_K_S.gguf"},
+		{Path: "Q8_0/model-Q8_0-00001-of-00002.gguf"},
+		{Path: "Q8_0/model-Q8_0-00002-of-00002.gguf"},
+		{Path: "F16/model-F16-00001-of-00003.gguf"},
+		{Path: "F16/model-F16-00002-of-00003.gguf"},
+		{Path: "mmproj-model.gguf"},
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+		err      string
+	}{
+		{"quant that prefixes others", "Q2_K", []string{"model-Q2_K.gguf"}, ""},
+		{"longer quant", "Q2_K_S", []string{"model-Q2_K_S.gguf"}, ""},
+		{"dot separated", "q4_k_m", []string{"model.Q4_K_M.gguf"}, ""},
+		{"split model", "Q8_0", []string{"Q8_0/model-Q8_0-00001-of-00002.gguf", "Q8_0/model-Q8_0-00002-of-00002.gguf"}, ""},
+		{"exact path", "model-Q4_K_S.gguf", []string{"model-Q4_K_S.gguf"}, ""},
+		{"exact shard path", "Q8_0/model-Q8_0-00002-of-00002.gguf", []string{"Q8_0/model-Q8_0-00001-of-00002.gguf", "Q8_0/model-Q8_0-00002-of-00002.gguf"}, ""},
+		{"projector by name", "mmproj", []string{"mmproj-model.gguf"}, ""},
+		{"part of a quant", "Q4", nil, `no GGUF file matches "Q4"`},
+		{"missing shard", "F16", nil, "F16/model-F16-00003-of-00003.gguf is missing"},
+		{"no match", "IQ1_S", nil, `no GGUF file matches "IQ1_S"`},
+		{"several matches", "model", nil, "several files match"},
+		{"no selector", "", nil, "several files match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := Resolve(files, tt.selector)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, file := range resolved {
+				paths = append(paths, file.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("resolved %q, want %q", paths, tt.want)
+			}
+		})
+	}
+
+	// A lone model is picked without a selector, leaving projectors out
+	resolved, err := Resolve([]File{{Path: "model-Q4_K_M.gguf"}, {Path: "mmproj-model.gguf"}, {Path: "config.json"}}, "")
+	if err != nil || len(resolved) != 1 || resolved[0].Path != "model-Q4_K_M.gguf" {
+		t.Errorf("Resolve without a selector = %v, %v", resolved, err)
+	}
+	if _, err := Resolve([]File{{Path: "config.json"}}, ""); err == nil || !strings.Contains(err.Error(), "no GGUF files found") {
+		t.Errorf("Resolve without models = %v", err)
+	}
+}
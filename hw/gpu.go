@@ -0,0 +1,172 @@
+package hw
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pciVendors maps PCI vendor ids to vendor names
+var pciVendors = map[string]string{
+	"0x10de": "nvidia",
+	"0x1002": "amd",
+	"0x8086": "intel",
+}
+
+// detectGPUs lists the GPUs in /sys/class/drm, replacing NVIDIA and AMD
+// entries with the richer details from nvidia-smi and rocminfo when those
+// tools are installed
+func detectGPUs(src Source) []GPU {
+	gpus := readDRM(src.path("/sys/class/drm"))
+	if src.Run == nil {
+		return gpus
+	}
+
+	if output, err := src.Run("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader,nounits"); err == nil {
+		if found := parseNvidiaSMI(string(output)); len(found) > 0 {
+			gpus = append(withoutVendor(gpus, "nvidia"), found...)
+		}
+	}
+	if output, err := src.Run("rocminfo"); err == nil {
+		if found := parseROCmInfo(string(output)); len(found) > 0 {
+			gpus = append(withoutVendor(gpus, "amd"), found...)
+		}
+	}
+	return gpus
+}
+
+// readDRM lists the cards under /sys/class/drm, skipping connectors such as
+// card0-HDMI-A-1 and devices without a PCI vendor
+func readDRM(dir string) []GPU {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "card") && !strings.Contains(name, "-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var gpus []GPU
+	for _, name := range names {
+		device := filepath.Join(dir, name, "device")
+		vendorID := readTrimmed(filepath.Join(device, "vendor"))
+		if vendorID == "" {
+			continue
+		}
+
+		gpu := GPU{Vendor: vendorID, Source: "drm"}
+		if vendor, ok := pciVendors[vendorID]; ok {
+			gpu.Vendor = vendor
+		}
+
+		// The driver name is the best description sysfs offers
+		gpu.Name = name
+		for _, line := range strings.Split(readTrimmed(filepath.Join(device, "uevent")), "\n") {
+			if driver, ok := strings.CutPrefix(line, "DRIVER="); ok {
+				gpu.Name = name + " (" + driver + ")"
+			}
+		}
+
+		// amdgpu reports its memory, other drivers don't
+		if vram, err := strconv.ParseInt(readTrimmed(filepath.Join(device, "mem_info_vram_total")), 10, 64); err == nil {
+			gpu.VRAM = vram
+		}
+
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// parseNvidiaSMI parses lines of "name, memory in MiB"
+func parseNvidiaSMI(output string) []GPU {
+	var gpus []GPU
+	for _, line := range strings.Split(output, "\n") {
+		name, memory, found := strings.Cut(line, ",")
+		if !found {
+			continue
+		}
+
+		gpu := GPU{Vendor: "nvidia", Name: strings.TrimSpace(name), Source: "nvidia-smi"}
+		if mib, err := strconv.ParseInt(strings.TrimSpace(memory), 10, 64); err == nil {
+			gpu.VRAM = mib << 20
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// parseROCmInfo finds the GPU agents in rocminfo output. Each agent starts
+// with an "Agent N" line followed by "Key: value" lines.
+func parseROCmInfo(output string) []GPU {
+	var gpus []GPU
+	var name, marketing, deviceType string
+
+	flush := func() {
+		if deviceType == "GPU" {
+			gpu := GPU{Vendor: "amd", Name: marketing, Source: "rocminfo"}
+			if gpu.Name == "" {
+				gpu.Name = name
+			} else if name != "" {
+				gpu.Name += " (" + name + ")"
+			}
+			gpus = append(gpus, gpu)
+		}
+		name, marketing, deviceType = "", "", ""
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Agent ") {
+			flush()
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Name":
+			// Pools and caches further down have names of their own
+			if name == "" {
+				name = value
+			}
+		case "Marketing Name":
+			marketing = value
+		case "Device Type":
+			deviceType = value
+		}
+	}
+	flush()
+
+	return gpus
+}
+
+// withoutVendor returns gpus without those of vendor
+func withoutVendor(gpus []GPU, vendor string) []GPU {
+	var kept []GPU
+	for _, gpu := range gpus {
+		if gpu.Vendor != vendor {
+			kept = append(kept, gpu)
+		}
+	}
+	return kept
+}
+
+// readTrimmed returns the trimmed contents of a small sysfs file, or ""
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,246 @@
+// Package hw takes an inventory of the CPU, memory, NUMA topology and GPUs
+// from /proc, /sys and the vendor tools, so builds and presets can default
+// to what the machine has.
+package hw
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github/llamarunner/utils"
+)
+
+// cpuFeatures are the CPU flags that matter to llama.cpp, in display order.
+// ARM reports NEON as asimd and the dot product extension as asimddp.
+var cpuFeatures = []string{"avx", "avx2", "fma", "f16c", "avx512f", "avx512_vnni", "avx512_bf16", "amx_tile", "amx_int8", "amx_bf16", "asimd", "asimddp", "i8mm", "sve"}
+
+// Source is where the inventory is read from. Tests can point Root at a
+// fake /proc and /sys tree and stub out the vendor tools.
+type Source struct {
+	// Root is prepended to every /proc and /sys path
+	Root string
+
+	// Run runs a vendor tool such as nvidia-smi; nil skips the tools
+	Run func(name string, args ...string) ([]byte, error)
+}
+
+// SystemSource reads the running system
+func SystemSource() Source {
+	return Source{
+		Root: "/",
+		Run: func(name string, args ...string) ([]byte, error) {
+			if _, err := exec.LookPath(name); err != nil {
+				return nil, err
+			}
+			return exec.Command(name, args...).Output()
+		},
+	}
+}
+
+// CPU describes the processors
+type CPU struct {
+	Model   string
+	Sockets int
+	Cores   int
+	Threads int
+
+	// Flags holds every flag reported by /proc/cpuinfo
+	Flags map[string]bool
+}
+
+// Features returns the flags relevant to llama.cpp that the CPU has
+func (c *CPU) Features() []string {
+	var features []string
+	for _, flag := range cpuFeatures {
+		if c.Flags[flag] {
+			features = append(features, flag)
+		}
+	}
+	return features
+}
+
+// NUMANode is one memory node
+type NUMANode struct {
+	ID       int
+	CPUs     string
+	MemTotal int64
+}
+
+// GPU is one graphics device
+type GPU struct {
+	// Vendor is "nvidia", "amd", "intel" or the PCI vendor id
+	Vendor string
+	Name   string
+
+	// VRAM is the device memory in bytes, when known
+	VRAM int64
+
+	// Source says where the GPU was found: drm, nvidia-smi or rocminfo
+	Source string
+}
+
+// Inventory is the hardware of a machine
+type Inventory struct {
+	CPU    CPU
+	Memory *utils.MemInfo
+	NUMA   []NUMANode
+	GPUs   []GPU
+}
+
+// HasVendor reports whether a GPU of the given vendor is present
+func (inv *Inventory) HasVendor(vendor string) bool {
+	for _, gpu := range inv.GPUs {
+		if gpu.Vendor == vendor {
+			return true
+		}
+	}
+	return false
+}
+
+// Vendors returns the distinct GPU vendors present
+func (inv *Inventory) Vendors() []string {
+	var vendors []string
+	for _, gpu := range inv.GPUs {
+		if !containsVendor(vendors, gpu.Vendor) {
+			vendors = append(vendors, gpu.Vendor)
+		}
+	}
+	return vendors
+}
+
+// Detect reads the inventory from src. Missing sources leave their part of
+// the inventory empty rather than failing.
+func Detect(src Source) *Inventory {
+	inv := &Inventory{}
+	inv.CPU = DetectCPU(src)
+	inv.Memory, _ = utils.ReadMemInfo(src.path("/proc/meminfo"))
+	inv.NUMA = readNUMA(src.path("/sys/devices/system/node"))
+	inv.GPUs = detectGPUs(src)
+	return inv
+}
+
+// DetectCPU reads only the CPU from src, without running the GPU tools
+// Detect does
+func DetectCPU(src Source) CPU {
+	return readCPUInfo(src.path("/proc/cpuinfo"))
+}
+
+// path returns an absolute system path under the source root
+func (src Source) path(path string) string {
+	return filepath.Join(src.Root, path)
+}
+
+// readCPUInfo parses /proc/cpuinfo. Cores are counted as distinct physical
+// and core id pairs, which ARM kernels don't report, so there every
+// processor counts as a core.
+func readCPUInfo(path string) CPU {
+	cpu := CPU{Flags: map[string]bool{}}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cpu
+	}
+	defer f.Close()
+
+	sockets := map[string]bool{}
+	cores := map[string]bool{}
+	physical := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		switch name {
+		case "processor":
+			cpu.Threads++
+		case "model name", "Model":
+			if cpu.Model == "" {
+				cpu.Model = value
+			}
+		case "physical id":
+			physical = value
+			sockets[value] = true
+		case "core id":
+			cores[physical+"/"+value] = true
+		case "flags", "Features":
+			for _, flag := range strings.Fields(value) {
+				cpu.Flags[flag] = true
+			}
+		}
+	}
+
+	cpu.Sockets = len(sockets)
+	if cpu.Sockets == 0 && cpu.Threads > 0 {
+		cpu.Sockets = 1
+	}
+	cpu.Cores = len(cores)
+	if cpu.Cores == 0 {
+		cpu.Cores = cpu.Threads
+	}
+	return cpu
+}
+
+// readNUMA lists the memory nodes under /sys/devices/system/node
+func readNUMA(dir string) []NUMANode {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var nodes []NUMANode
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "node"))
+		if err != nil || !strings.HasPrefix(entry.Name(), "node") {
+			continue
+		}
+
+		node := NUMANode{ID: id}
+		if data, err := os.ReadFile(filepath.Join(dir, entry.Name(), "cpulist")); err == nil {
+			node.CPUs = strings.TrimSpace(string(data))
+		}
+		node.MemTotal = readNodeMemTotal(filepath.Join(dir, entry.Name(), "meminfo"))
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// readNodeMemTotal reads a node's meminfo, where lines look like
+// "Node 0 MemTotal:       32768000 kB"
+func readNodeMemTotal(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[2] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[3], 10, 64)
+			if err == nil {
+				return kb * 1024
+			}
+		}
+	}
+	return 0
+}
+
+// containsVendor reports whether vendors contains vendor
+func containsVendor(vendors []string, vendor string) bool {
+	for _, v := range vendors {
+		if v == vendor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package hw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github/llamarunner/utils"
+)
+
+// writeTree creates the files under root, keyed by absolute system path
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeTools returns a Source.Run answering with the given output per tool;
+// other tools are not installed
+func fakeTools(outputs map[string]string) func(string, ...string) ([]byte, error) {
+	return func(name string, args ...string) ([]byte, error) {
+		output, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", name)
+		}
+		return []byte(output), nil
+	}
+}
+
+const x86CPUInfo = `processor	: 0
+model name	: Example CPU 9000
+physical id	: 0
+core id		: 0
+flags		: fpu sse avx avx2 fma f16c avx512f
+
+processor	: 1
+model name	: Example CPU 9000
+physical id	: 0
+core id		: 0
+flags		: fpu sse avx avx2 fma f16c avx512f
+
+processor	: 2
+model name	: Example CPU 9000
+physical id	: 1
+core id		: 0
+flags		: fpu sse avx avx2 fma f16c avx512f
+
+processor	: 3
+model name	: Example CPU 9000
+physical id	: 1
+core id		: 1
+flags		: fpu sse avx avx2 fma f16c avx512f
+`
+
+const armCPUInfo = `processor	: 0
+BogoMIPS	: 50.00
+Features	: fp asimd asimddp i8mm
+
+processor	: 1
+BogoMIPS	: 50.00
+Features	: fp asimd asimddp i8mm
+`
+
+const rocmInfo = `*******
+Agent 1
+*******
+  Name:                    AMD Ryzen 9
+  Marketing Name:          AMD Ryzen 9
+  Device Type:             CPU
+*******
+Agent 2
+*******
+  Name:                    gfx1100
+  Marketing Name:          Radeon RX 7900 XTX
+  Device Type:             GPU
+  Pool Info:
+    Pool 1
+      Name:                GLOBAL
+`
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		tools   map[string]string
+		want    Inventory
+		vendors []string
+	}{
+		{
+			name: "empty root",
+			want: Inventory{CPU: CPU{Flags: map[string]bool{}}},
+		},
+		{
+			name: "two socket x86 with an NVIDIA GPU",
+			files: map[string]string{
+				"/proc/cpuinfo":                          x86CPUInfo,
+				"/proc/meminfo":                          "MemTotal:       65536000 kB\nMemFree:        1024 kB\nMemAvailable:   32768000 kB\nSwapFree:       0 kB\n",
+				"/sys/devices/system/node/node1/cpulist": "2-3\n",
+				"/sys/devices/system/node/node1/meminfo": "Node 1 MemTotal:       32768000 kB\n",
+				"/sys/devices/system/node/node0/cpulist": "0-1\n",
+				"/sys/devices/system/node/node0/meminfo": "Node 0 MemTotal:       32768000 kB\n",
+				"/sys/devices/system/node/possible":      "0-1\n",
+				"/sys/class/drm/card0/device/vendor":     "0x10de\n",
+				"/sys/class/drm/card0/device/uevent":     "DRIVER=nvidia\nPCI_ID=10DE:2684\n",
+				"/sys/class/drm/card0-HDMI-A-1/status":   "connected\n",
+				"/sys/class/drm/card1/device/vendor":     "0x8086\n",
+			},
+			tools: map[string]string{"nvidia-smi": "NVIDIA GeForce RTX 4090, 24564\n"},
+			want: Inventory{
+				CPU:    CPU{Model: "Example CPU 9000", Sockets: 2, Cores: 3, Threads: 4},
+				Memory: &utils.MemInfo{Total: 65536000 << 10, Free: 1024 << 10, Available: 32768000 << 10},
+				NUMA: []NUMANode{
+					{ID: 0, CPUs: "0-1", MemTotal: 32768000 << 10},
+					{ID: 1, CPUs: "2-3", MemTotal: 32768000 << 10},
+				},
+				GPUs: []GPU{
+					{Vendor: "intel", Name: "card1", Source: "drm"},
+					{Vendor: "nvidia", Name: "NVIDIA GeForce RTX 4090", VRAM: 24564 << 20, Source: "nvidia-smi"},
+				},
+			},
+			vendors: []string{"intel", "nvidia"},
+		},
+		{
+			name: "NVIDIA GPU without nvidia-smi",
+			files: map[string]string{
+				"/sys/class/drm/card0/device/vendor": "0x10de\n",
+				"/sys/class/drm/card0/device/uevent": "DRIVER=nouveau\n",
+			},
+			want: Inventory{
+				CPU:  CPU{Flags: map[string]bool{}},
+				GPUs: []GPU{{Vendor: "nvidia", Name: "card0 (nouveau)", Source: "drm"}},
+			},
+			vendors: []string{"nvidia"},
+		},
+		{
+			name: "ARM with an AMD GPU",
+			files: map[string]string{
+				"/proc/cpuinfo":                                   armCPUInfo,
+				"/sys/class/drm/card0/device/vendor":              "0x1002\n",
+				"/sys/class/drm/card0/device/mem_info_vram_total": "25753026560\n",
+				"/sys/class/drm/card0/device/uevent":              "DRIVER=amdgpu\n",
+				"/sys/class/drm/card2/device/vendor":              "0x1af4\n",
+				"/sys/class/drm/renderD128/device/vendor":         "0x1002\n",
+			},
+			tools: map[string]string{"rocminfo": rocmInfo},
+			want: Inventory{
+				CPU: CPU{Sockets: 1, Cores: 2, Threads: 2},
+				GPUs: []GPU{
+					{Vendor: "0x1af4", Name: "card2", Source: "drm"},
+					{Vendor: "amd", Name: "Radeon RX 7900 XTX (gfx1100)", Source: "rocminfo"},
+				},
+			},
+			vendors: []string{"0x1af4", "amd"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, tt.files)
+			inv := Detect(Source{Root: root, Run: fakeTools(tt.tools)})
+
+			// Flags are checked through Features
+			inv.CPU.Flags, tt.want.CPU.Flags = nil, nil
+			if !reflect.DeepEqual(*inv, tt.want) {
+				t.Errorf("Detect =\n%+v\nwant\n%+v", *inv, tt.want)
+			}
+			if got := inv.Vendors(); !reflect.DeepEqual(got, tt.vendors) {
+				t.Errorf("Vendors = %q, want %q", got, tt.vendors)
+			}
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		cpuinfo string
+		want    []string
+	}{
+		{x86CPUInfo, []string{"avx", "avx2", "fma", "f16c", "avx512f"}},
+		{armCPUInfo, []string{"asimd", "asimddp", "i8mm"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{"/proc/cpuinfo": tt.cpuinfo})
+		cpu := DetectCPU(Source{Root: root})
+		if got := cpu.Features(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Features = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseNvidiaSMI(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []GPU
+	}{
+		{"", nil},
+		{"NVIDIA A100-SXM4-80GB, 81920\nNVIDIA A100-SXM4-80GB, 81920\n", []GPU{
+			{Vendor: "nvidia", Name: "NVIDIA A100-SXM4-80GB", VRAM: 81920 << 20, Source: "nvidia-smi"},
+			{Vendor: "nvidia", Name: "NVIDIA A100-SXM4-80GB", VRAM: 81920 << 20, Source: "nvidia-smi"},
+		}},
+		{"NVIDIA GeForce GT 710, [N/A]\n", []GPU{
+			{Vendor: "nvidia", Name: "NVIDIA GeForce GT 710", Source: "nvidia-smi"},
+		}},
+		{"No devices were found\n", nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseNvidiaSMI(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNvidiaSMI(%q) = %+v, want %+v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestParseROCmInfo(t *testing.T) {
+	tests := []struct {
+		output string
+		want   []GPU
+	}{
+		{"", nil},
+		{rocmInfo, []GPU{{Vendor: "amd", Name: "Radeon RX 7900 XTX (gfx1100)", Source: "rocminfo"}}},
+		{"Agent 1\n  Name: gfx90a\n  Device Type: GPU\nAgent 2\n  Name: gfx90a\n  Marketing Name:\n  Device Type: GPU\n", []GPU{
+			{Vendor: "amd", Name: "gfx90a", Source: "rocminfo"},
+			{Vendor: "amd", Name: "gfx90a", Source: "rocminfo"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := parseROCmInfo(tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseROCmInfo(%q) = %+v, want %+v", tt.output, got, tt.want)
+		}
+	}
+}
@@ -150,11 +150,15 @@ while true; do
     case $REPLY in
         [Yy]* ) 
             echo "Creating default configuration..."
+            # Follow the XDG Base Directory specification
+            CONFIG_DIR="${XDG_CONFIG_HOME:-$HOME/.config}/llamarunner"
+            MODEL_DIR="${XDG_DATA_HOME:-$HOME/.local/share}/llamarunner/models"
+
             # Create config directory if it doesn't exist
-            mkdir -p "$HOME/.llama-presets"
+            mkdir -p "$CONFIG_DIR"
             
             # Create default config file
-            cat > "$HOME/.llama-presets/settings.toml" << EOF
+            cat > "$CONFIG_DIR/settings.toml" << EOF
 # llamarunner configuration file
 # This file is automatically generated and can be edited manually
 
@@ -162,19 +166,28 @@ while true; do
 llama_cpp_path = "$HOME/llama.cpp"
 
 # Directory where model files are stored
-model_path = "$HOME/.llama-presets/models"
+model_path = "$MODEL_DIR"
 
 # Directory where preset configuration files are stored
-config_path = "$HOME/.llama-presets"
+config_path = "$CONFIG_DIR"
 
 # Default host for any server operations
 host = "localhost"
 
 # Default port for any server operations
 port = "8080"
+
+# Model hub used by "llamarunner model pull"
+hub_url = "https://huggingface.co"
+
+# Build profile used by "llamarunner build" (see "llamarunner build profile")
+build_profile = ""
+
+# Settings file format, upgraded automatically by llamarunner
+schema_version = 4
 EOF
             
-            echo "Default configuration created at $HOME/.llama-presets/settings.toml"
+            echo "Default configuration created at $CONFIG_DIR/settings.toml"
             break
             ;;
         [Nn]* ) 
@@ -0,0 +1,174 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github/llamarunner/utils"
+)
+
+// imatrixDir is the cache subdirectory holding importance matrices
+const imatrixDir = "imatrix"
+
+// imatrixTempSuffix marks an importance matrix still being computed.
+// llama-imatrix picks its output format from the extension, so it is kept.
+const imatrixTempSuffix = ".tmp.imatrix"
+
+// hashPrefixLength is how much of a sha256 is used in cache file names
+const hashPrefixLength = 16
+
+// datasetNamePattern restricts dataset names to safe file names
+var datasetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// lowBitTypes are quantization types that lose too much quality without an
+// importance matrix; llama-quantize refuses some of them outright
+var lowBitTypes = map[string]bool{
+	"IQ1_S":   true,
+	"IQ1_M":   true,
+	"IQ2_XXS": true,
+	"IQ2_XS":  true,
+	"IQ2_S":   true,
+	"IQ2_M":   true,
+	"IQ3_XXS": true,
+	"IQ3_XS":  true,
+	"IQ3_S":   true,
+	"IQ3_M":   true,
+	"IQ4_XS":  true,
+	"IQ4_NL":  true,
+	"Q2_K":    true,
+	"Q2_K_S":  true,
+	"Q3_K_S":  true,
+}
+
+// NeedsIMatrix reports whether qtype should be quantized with an importance
+// matrix
+func NeedsIMatrix(qtype string) bool {
+	return lowBitTypes[qtype]
+}
+
+// IMatrixDir returns the cache directory of computed importance matrices
+func IMatrixDir(ctx *utils.Context) string {
+	return filepath.Join(ctx.Dirs.Cache, imatrixDir)
+}
+
+// IMatrixPath returns the cache path of the importance matrix computed for a
+// model on a dataset, keyed by both hashes
+func IMatrixPath(ctx *utils.Context, modelSHA256, datasetSHA256 string) string {
+	name := fmt.Sprintf("%s-%s.imatrix", shortHash(modelSHA256), shortHash(datasetSHA256))
+	return filepath.Join(IMatrixDir(ctx), name)
+}
+
+// IMatrixTempPath returns where the importance matrix for path is written
+// while it is computed
+func IMatrixTempPath(path string) string {
+	return strings.TrimSuffix(path, ".imatrix") + imatrixTempSuffix
+}
+
+// FindIMatrix returns the most recently computed importance matrix for the
+// model with the given hash, or "" if there is none
+func FindIMatrix(ctx *utils.Context, modelSHA256 string) (string, error) {
+	pattern := filepath.Join(IMatrixDir(ctx), shortHash(modelSHA256)+"-*.imatrix")
+	found, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	// Skip the temporary output of a computation still running or killed
+	var matches []string
+	for _, path := range found {
+		if !strings.HasSuffix(path, imatrixTempSuffix) {
+			matches = append(matches, path)
+		}
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, errA := os.Stat(matches[i])
+		b, errB := os.Stat(matches[j])
+		return errA == nil && errB == nil && a.ModTime().After(b.ModTime())
+	})
+	return matches[0], nil
+}
+
+// shortHash shortens a sha256 for use in file names
+func shortHash(hash string) string {
+	if len(hash) > hashPrefixLength {
+		return hash[:hashPrefixLength]
+	}
+	return hash
+}
+
+// DatasetPath returns the file of a named calibration dataset
+func DatasetPath(ctx *utils.Context, name string) string {
+	return filepath.Join(ctx.Dirs.Datasets(), name+".txt")
+}
+
+// ListDatasets returns the names of the stored calibration datasets
+func ListDatasets(ctx *utils.Context) ([]string, error) {
+	files, err := os.ReadDir(ctx.Dirs.Datasets())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".txt") {
+			names = append(names, strings.TrimSuffix(file.Name(), ".txt"))
+		}
+	}
+	return names, nil
+}
+
+// AddDataset copies a calibration text file into the datasets directory
+// under name
+func AddDataset(ctx *utils.Context, name, src string) error {
+	if !datasetNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid dataset name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+
+	dest := DatasetPath(ctx, name)
+	if utils.FileExists(dest) {
+		return fmt.Errorf("dataset %s already exists", name)
+	}
+
+	err := os.MkdirAll(ctx.Dirs.Datasets(), 0755)
+	if err != nil {
+		return err
+	}
+	return utils.CopyFile(src, dest, 0644)
+}
+
+// RemoveDataset deletes the named calibration dataset
+func RemoveDataset(ctx *utils.Context, name string) error {
+	if !datasetNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid dataset name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+
+	path := DatasetPath(ctx, name)
+	if !utils.FileExists(path) {
+		return fmt.Errorf("dataset %s not found", name)
+	}
+	return os.Remove(path)
+}
+
+// ResolveDataset returns the file for a dataset given by name or by path
+func ResolveDataset(ctx *utils.Context, arg string) (string, error) {
+	if path := DatasetPath(ctx, arg); datasetNamePattern.MatchString(arg) && utils.FileExists(path) {
+		return path, nil
+	}
+
+	path := ctx.Dirs.ExpandPath(arg)
+	if utils.FileExists(path) {
+		return path, nil
+	}
+	return "", fmt.Errorf("%s is neither a dataset name nor a file", arg)
+}
@@ -0,0 +1,90 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github/llamarunner/utils"
+)
+
+// testContext returns a context whose directories are under a temporary
+// home
+func testContext(t *testing.T) *utils.Context {
+	t.Helper()
+	home := t.TempDir()
+	return &utils.Context{Dirs: utils.NewDirs(home, func(string) string { return "" })}
+}
+
+func TestFindIMatrix(t *testing.T) {
+	ctx := testContext(t)
+	model := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	if path, err := FindIMatrix(ctx, model); path != "" || err != nil {
+		t.Fatalf("FindIMatrix without a cache = %q, %v", path, err)
+	}
+
+	older := IMatrixPath(ctx, model, "1111111111111111")
+	newer := IMatrixPath(ctx, model, "2222222222222222")
+	other := IMatrixPath(ctx, "bbbbbbbbbbbbbbbbbbbbbbbb", "3333333333333333")
+	running := IMatrixTempPath(IMatrixPath(ctx, model, "4444444444444444"))
+
+	now := time.Now()
+	for i, path := range []string{older, newer, other, running} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		modified := now.Add(time.Duration(i-4) * time.Hour)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := FindIMatrix(ctx, model)
+	if err != nil || path != newer {
+		t.Errorf("FindIMatrix = %q, %v; want %q", path, err, newer)
+	}
+
+	os.Remove(older)
+	os.Remove(newer)
+	if path, err := FindIMatrix(ctx, model); path != "" || err != nil {
+		t.Errorf("FindIMatrix with only a temporary file = %q, %v", path, err)
+	}
+}
+
+func TestRemoveDataset(t *testing.T) {
+	ctx := testContext(t)
+	src := filepath.Join(t.TempDir(), "calibration.txt")
+	if err := os.WriteFile(src, []byte("text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddDataset(ctx, "wiki", src); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file next to the datasets directory must not be reachable
+	outside := filepath.Join(filepath.Dir(ctx.Dirs.Datasets()), "settings.txt")
+	if err := os.WriteFile(outside, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../settings", "", ".hidden", "a/b", "missing"} {
+		if err := RemoveDataset(ctx, name); err == nil {
+			t.Errorf("RemoveDataset(%q) succeeded", name)
+		}
+	}
+	if !utils.FileExists(outside) {
+		t.Error("a file outside the datasets directory was removed")
+	}
+
+	if err := RemoveDataset(ctx, "wiki"); err != nil {
+		t.Fatal(err)
+	}
+	if utils.FileExists(DatasetPath(ctx, "wiki")) {
+		t.Error("dataset still exists")
+	}
+}
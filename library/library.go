@@ -0,0 +1,236 @@
+// Package library scans model directories and keeps a cached index of the
+// GGUF files found there.
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/utils"
+)
+
+// indexFile is the name of the cached index in the cache dir
+const indexFile = "models.json"
+
+// Entry describes one GGUF file in the library
+type Entry struct {
+	Path          string    `json:"path"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mtime"`
+	SHA256        string    `json:"sha256,omitempty"`
+	Architecture  string    `json:"architecture,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	ContextLength uint64    `json:"context_length,omitempty"`
+
+	// Error is set when the file could not be parsed as GGUF
+	Error string `json:"error,omitempty"`
+}
+
+// Index is the cached catalogue of model files
+type Index struct {
+	Entries []Entry `json:"models"`
+
+	path string
+}
+
+// ScanOptions controls how Scan indexes files
+type ScanOptions struct {
+	// Rescan ignores cached entries and re-reads every file
+	Rescan bool
+
+	// NoHash skips computing sha256 for new or changed files
+	NoHash bool
+}
+
+// IndexPath returns where the index is cached
+func IndexPath(ctx *utils.Context) string {
+	return filepath.Join(ctx.Dirs.Cache, indexFile)
+}
+
+// LoadIndex reads the cached index, returning an empty one if none exists
+func LoadIndex(ctx *utils.Context) (*Index, error) {
+	index := &Index{path: IndexPath(ctx)}
+
+	data, err := os.ReadFile(index.path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", index.path, err)
+	}
+
+	return index, nil
+}
+
+// Save writes the index back to the cache dir
+func (idx *Index) Save() error {
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].Path < idx.Entries[j].Path
+	})
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(idx.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(idx.path, data, 0644)
+}
+
+// Lookup returns the entry for path, if indexed
+func (idx *Index) Lookup(path string) (*Entry, bool) {
+	path = resolvePath(path)
+	for i := range idx.Entries {
+		if idx.Entries[i].Path == path {
+			return &idx.Entries[i], true
+		}
+	}
+	return nil, false
+}
+
+// Remove drops the entry for path from the index
+func (idx *Index) Remove(path string) {
+	path = resolvePath(path)
+	for i := range idx.Entries {
+		if idx.Entries[i].Path == path {
+			idx.Entries = append(idx.Entries[:i], idx.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Add indexes a single file, reusing the cached entry when its size and
+// modification time are unchanged
+func (idx *Index) Add(path string, hash bool) (*Entry, error) {
+	path = resolvePath(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := idx.Lookup(path); ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		if cached.SHA256 == "" && hash {
+			cached.SHA256, err = utils.HashFile(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return cached, nil
+	}
+
+	entry, err := newEntry(path, info, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.Remove(path)
+	idx.Entries = append(idx.Entries, *entry)
+	return &idx.Entries[len(idx.Entries)-1], nil
+}
+
+// newEntry reads metadata and optionally hashes the file at path
+func newEntry(path string, info fs.FileInfo, hash bool) (*Entry, error) {
+	entry := &Entry{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+
+	file, err := gguf.Open(path)
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Architecture = file.Architecture()
+		entry.Quantization = file.FileType()
+		entry.ContextLength, _ = file.ArchUint("context_length")
+	}
+
+	if hash {
+		entry.SHA256, err = utils.HashFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// Scan walks the configured model directories and updates the cached index,
+// dropping entries for files that no longer exist
+func Scan(ctx *utils.Context, opts ScanOptions) (*Index, error) {
+	index, err := LoadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Rescan {
+		index.Entries = nil
+	}
+
+	seen := map[string]bool{}
+	for _, dir := range utils.ModelDirs(ctx) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".gguf") {
+				return nil
+			}
+
+			path = resolvePath(path)
+			seen[path] = true
+
+			if cached, ok := index.Lookup(path); !ok || (cached.SHA256 == "" && !opts.NoHash) || changed(cached) {
+				fmt.Printf("Indexing %s...\n", path)
+			}
+
+			_, err = index.Add(path, !opts.NoHash)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error scanning %s: %v", dir, err)
+		}
+	}
+
+	// Forget files that were deleted or moved away
+	kept := index.Entries[:0]
+	for _, entry := range index.Entries {
+		if seen[entry.Path] || utils.FileExists(entry.Path) {
+			kept = append(kept, entry)
+		}
+	}
+	index.Entries = kept
+
+	err = index.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// changed reports whether the file behind a cached entry was modified
+func changed(entry *Entry) bool {
+	info, err := os.Stat(entry.Path)
+	return err != nil || info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime)
+}
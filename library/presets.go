@@ -0,0 +1,123 @@
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/utils"
+)
+
+// MissingModel is a preset whose model file does not exist
+type MissingModel struct {
+	Preset string
+	Model  string
+}
+
+// PresetReferences maps each model file referenced by a preset, including
+// every shard of a split model, to the names of the presets using it. It
+// also reports presets pointing at files that don't exist.
+func PresetReferences(ctx *utils.Context) (map[string][]string, []MissingModel, error) {
+	names, err := utils.ListPresets(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(names)
+
+	refs := map[string][]string{}
+	var missing []MissingModel
+
+	for _, name := range names {
+		preset, err := utils.LoadPreset(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		model := preset.ModelPath(ctx)
+		if model == "" {
+			continue
+		}
+
+		if !utils.FileExists(model) {
+			missing = append(missing, MissingModel{Preset: name, Model: model})
+			continue
+		}
+
+		for _, shard := range gguf.ShardPaths(model) {
+			shard = resolvePath(shard)
+			refs[shard] = append(refs[shard], name)
+		}
+	}
+
+	return refs, missing, nil
+}
+
+// FilePresets returns the names of the presets using any of files
+func FilePresets(ctx *utils.Context, files []string) ([]string, error) {
+	refs, _, err := PresetReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var presets []string
+	for _, file := range files {
+		for _, name := range refs[resolvePath(file)] {
+			if !seen[name] {
+				seen[name] = true
+				presets = append(presets, name)
+			}
+		}
+	}
+	sort.Strings(presets)
+	return presets, nil
+}
+
+// resolvePath returns an absolute path with symlinks resolved where possible,
+// so the same file is recognised through different paths
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return path
+}
+
+// RepointPresets changes every preset using one of oldPaths as its model to
+// use newPath instead, returning the names of the updated presets
+func RepointPresets(ctx *utils.Context, oldPaths []string, newPath string) ([]string, error) {
+	old := map[string]bool{}
+	for _, path := range oldPaths {
+		old[resolvePath(path)] = true
+	}
+
+	names, err := utils.ListPresets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var updated []string
+	for _, name := range names {
+		preset, err := utils.LoadPreset(ctx, name)
+		if err != nil {
+			return updated, err
+		}
+
+		model := preset.ModelPath(ctx)
+		if model == "" || !old[resolvePath(model)] {
+			continue
+		}
+
+		err = preset.SetModel(newPath)
+		if err != nil {
+			return updated, fmt.Errorf("error updating preset %s: %v", name, err)
+		}
+		updated = append(updated, name)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,68 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github/llamarunner/utils"
+)
+
+// provenanceSuffix is appended to a model path to name its provenance file
+const provenanceSuffix = ".provenance.json"
+
+// Provenance records how a model file was produced
+type Provenance struct {
+	// Tool is the llama.cpp program that wrote the file, e.g. llama-quantize
+	Tool string `json:"tool"`
+
+	Source       string `json:"source"`
+	SourceSHA256 string `json:"source_sha256,omitempty"`
+
+	// Type is the output type, e.g. a quantization such as Q4_K_M
+	Type string `json:"type,omitempty"`
+
+	IMatrix       string `json:"imatrix,omitempty"`
+	IMatrixSHA256 string `json:"imatrix_sha256,omitempty"`
+
+	// Dataset is the calibration data an importance matrix was computed on
+	Dataset       string `json:"dataset,omitempty"`
+	DatasetSHA256 string `json:"dataset_sha256,omitempty"`
+
+	LlamaCppCommit string    `json:"llama_cpp_commit"`
+	Command        []string  `json:"command"`
+	Created        time.Time `json:"created"`
+}
+
+// ProvenancePath returns the provenance file kept next to a model
+func ProvenancePath(model string) string {
+	return model + provenanceSuffix
+}
+
+// WriteProvenance saves provenance next to model
+func WriteProvenance(model string, provenance *Provenance) error {
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(ProvenancePath(model), append(data, '\n'), 0644)
+}
+
+// ReadProvenance loads the provenance of model, returning nil if it has none
+func ReadProvenance(model string) (*Provenance, error) {
+	data, err := os.ReadFile(ProvenancePath(model))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := &Provenance{}
+	err = json.Unmarshal(data, provenance)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", ProvenancePath(model), err)
+	}
+	return provenance, nil
+}
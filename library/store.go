@@ -0,0 +1,335 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github/llamarunner/gguf"
+	"github/llamarunner/utils"
+)
+
+// blobPrefix starts the name of every blob in the store
+const blobPrefix = "sha256-"
+
+// Alias is a named model in the store
+type Alias struct {
+	Name  string
+	Files []string
+
+	// Blobs are the store files behind Files, in the same order
+	Blobs []string
+	Size  int64
+}
+
+// StoreOptions controls how AddAlias imports files
+type StoreOptions struct {
+	// Move removes the original files once they are in the store
+	Move bool
+
+	// Force replaces an existing alias
+	Force bool
+}
+
+// BlobPath returns the store file for content with the given sha256
+func BlobPath(ctx *utils.Context, sum string) string {
+	return filepath.Join(utils.StoreBlobsDir(ctx), blobPrefix+sum)
+}
+
+// IsBlob reports whether path is, or links to, a file in the store's blobs.
+// Blobs are shared by aliases and only removed by CollectGarbage and
+// RemoveUnusedBlobs.
+func IsBlob(ctx *utils.Context, path string) bool {
+	return filepath.Dir(resolvePath(path)) == resolvePath(utils.StoreBlobsDir(ctx))
+}
+
+// AddAlias imports a model, including every shard of a split model, into
+// the store under name. Files already in the store are not stored twice.
+func AddAlias(ctx *utils.Context, name, model string, opts StoreOptions) (*Alias, error) {
+	if !utils.ValidAliasName(name) {
+		return nil, fmt.Errorf("invalid alias %q: use letters, digits and . _ : + -", name)
+	}
+	aliasDir := utils.AliasDir(ctx, name)
+	if utils.FileExists(aliasDir) && !opts.Force {
+		return nil, fmt.Errorf("alias %s already exists (use --force to replace it)", name)
+	}
+
+	files := gguf.ShardPaths(model)
+	for _, file := range files {
+		if !utils.FileExists(file) {
+			return nil, fmt.Errorf("%s is missing", file)
+		}
+	}
+
+	index, err := LoadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(utils.StoreBlobsDir(ctx), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the alias next to its final place so it appears all at once
+	tmpDir := filepath.Join(filepath.Dir(aliasDir), "."+name+".tmp")
+	os.RemoveAll(tmpDir)
+	err = os.MkdirAll(tmpDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, file := range files {
+		// The index keeps hashes, so files seen before aren't read again
+		if cached, ok := index.Lookup(file); !ok || cached.SHA256 == "" || changed(cached) {
+			fmt.Printf("Hashing %s...\n", file)
+		}
+		entry, err := index.Add(file, true)
+		if err != nil {
+			return nil, err
+		}
+
+		// Files of another alias are already in the store and must stay
+		move := opts.Move && !IsBlob(ctx, file)
+
+		blob := BlobPath(ctx, entry.SHA256)
+		err = storeBlob(file, blob, move)
+		if err != nil {
+			return nil, fmt.Errorf("error storing %s: %v", file, err)
+		}
+
+		// Relative links keep working if the data dir moves
+		link := filepath.Join(tmpDir, filepath.Base(file))
+		err = os.Symlink(filepath.Join("..", "..", "blobs", filepath.Base(blob)), link)
+		if err != nil {
+			return nil, err
+		}
+
+		if provenance := ProvenancePath(file); utils.FileExists(provenance) {
+			err = utils.CopyFile(provenance, ProvenancePath(link), 0644)
+			if err != nil {
+				return nil, err
+			}
+			if move {
+				os.Remove(provenance)
+			}
+		}
+
+		if move {
+			index.Remove(file)
+		}
+	}
+
+	err = os.RemoveAll(aliasDir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmpDir, aliasDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = index.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadAlias(ctx, name)
+}
+
+// storeBlob puts file into the store as blob. An existing blob already has
+// the same contents, so a moved file is simply removed. Otherwise the file
+// is renamed into the store when moving and copied when not. A hard link
+// would share the original's inode, so writing to the original in place
+// would change every alias using the blob. Blobs are made read-only.
+func storeBlob(file, blob string, move bool) error {
+	if utils.FileExists(blob) {
+		if move {
+			return os.Remove(file)
+		}
+		return nil
+	}
+
+	if move && os.Rename(file, blob) == nil {
+		return os.Chmod(blob, 0444)
+	}
+
+	tmp := blob + ".tmp"
+	os.Remove(tmp)
+	err := utils.CopyFile(file, tmp, 0444)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	err = os.Rename(tmp, blob)
+	if err != nil {
+		return err
+	}
+
+	if move {
+		return os.Remove(file)
+	}
+	return nil
+}
+
+// LoadAlias reads the files and blobs of an alias
+func LoadAlias(ctx *utils.Context, name string) (*Alias, error) {
+	files, err := utils.AliasFiles(ctx, name)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("alias %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	alias := &Alias{Name: name, Files: files}
+	for _, file := range files {
+		blob, err := filepath.EvalSymlinks(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: blob is missing", file)
+		}
+		alias.Blobs = append(alias.Blobs, blob)
+
+		if info, err := os.Stat(blob); err == nil {
+			alias.Size += info.Size()
+		}
+	}
+	return alias, nil
+}
+
+// ListAliases returns every alias in the store, sorted by name
+func ListAliases(ctx *utils.Context) ([]*Alias, error) {
+	entries, err := os.ReadDir(filepath.Join(ctx.Dirs.Store(), "aliases"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []*Alias
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		alias, err := LoadAlias(ctx, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+// RemoveAlias deletes an alias. Its blobs stay in the store until
+// CollectGarbage finds them unused.
+func RemoveAlias(ctx *utils.Context, name string) error {
+	if !utils.ValidAliasName(name) || !utils.FileExists(utils.AliasDir(ctx, name)) {
+		return fmt.Errorf("alias %s not found", name)
+	}
+	return os.RemoveAll(utils.AliasDir(ctx, name))
+}
+
+// AliasPresets returns the presets whose model is the given alias
+func AliasPresets(ctx *utils.Context, name string) ([]string, error) {
+	names, err := utils.ListPresets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var users []string
+	for _, preset := range names {
+		p, err := utils.LoadPreset(ctx, preset)
+		if err != nil {
+			return nil, err
+		}
+		if model := p.ModelPath(ctx); model != "" && filepath.Dir(model) == utils.AliasDir(ctx, name) {
+			users = append(users, preset)
+		}
+	}
+	return users, nil
+}
+
+// CollectGarbage removes blobs no alias refers to, along with leftovers of
+// interrupted imports, and returns the removed files and the space freed.
+// With dryRun nothing is removed.
+func CollectGarbage(ctx *utils.Context, dryRun bool) ([]string, int64, error) {
+	used, err := usedBlobs(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := os.ReadDir(utils.StoreBlobsDir(ctx))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var removed []string
+	var freed int64
+	for _, entry := range entries {
+		if used[entry.Name()] {
+			continue
+		}
+
+		path := filepath.Join(utils.StoreBlobsDir(ctx), entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return removed, freed, err
+		}
+		if !dryRun {
+			err = os.Remove(path)
+			if err != nil {
+				return removed, freed, err
+			}
+		}
+		removed = append(removed, path)
+		freed += info.Size()
+	}
+
+	return removed, freed, nil
+}
+
+// RemoveUnusedBlobs removes those of blobs no alias refers to, such as the
+// blobs of a removed alias, leaving the rest of the store to CollectGarbage
+func RemoveUnusedBlobs(ctx *utils.Context, blobs []string) error {
+	used, err := usedBlobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		if used[filepath.Base(blob)] {
+			continue
+		}
+		err = os.Remove(blob)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// usedBlobs returns the names of the blobs aliases refer to
+func usedBlobs(ctx *utils.Context) (map[string]bool, error) {
+	aliases, err := ListAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, alias := range aliases {
+		for _, blob := range alias.Blobs {
+			used[filepath.Base(blob)] = true
+		}
+	}
+	return used, nil
+}
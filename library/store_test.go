@@ -0,0 +1,220 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github/llamarunner/utils"
+)
+
+// writeModel creates a model file with the given contents and returns its
+// path
+func writeModel(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// blobNames returns the names of the files in the store's blobs
+func blobNames(t *testing.T, ctx *utils.Context) []string {
+	t.Helper()
+	entries, err := os.ReadDir(utils.StoreBlobsDir(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestAddAliasDeduplicates(t *testing.T) {
+	ctx := testContext(t)
+	dir := t.TempDir()
+	first := writeModel(t, filepath.Join(dir, "a"), "model.gguf", "weights")
+	second := writeModel(t, filepath.Join(dir, "b"), "model.gguf", "weights")
+
+	a, err := AddAlias(ctx, "a", first, StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := AddAlias(ctx, "b", second, StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a.Blobs, b.Blobs) || len(blobNames(t, ctx)) != 1 {
+		t.Fatalf("identical files stored as %v and %v", a.Blobs, b.Blobs)
+	}
+	if !utils.FileExists(first) || !utils.FileExists(second) {
+		t.Fatal("originals removed without --move")
+	}
+
+	// The blob is a copy, so writing to the original leaves it alone
+	original, _ := os.Stat(first)
+	blob, _ := os.Stat(a.Blobs[0])
+	if os.SameFile(original, blob) {
+		t.Error("the blob shares its inode with the original")
+	}
+	if blob.Mode().Perm()&0222 != 0 {
+		t.Errorf("blob mode %v, want read-only", blob.Mode())
+	}
+	if err := os.WriteFile(first, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(a.Files[0]); string(data) != "weights" {
+		t.Errorf("alias reads %q after the original changed", data)
+	}
+
+	if _, err := AddAlias(ctx, "a", second, StoreOptions{}); err == nil {
+		t.Error("replaced an existing alias without Force")
+	}
+}
+
+func TestAddAliasSplitModel(t *testing.T) {
+	ctx := testContext(t)
+	dir := t.TempDir()
+	first := writeModel(t, dir, "model-00001-of-00002.gguf", "part one")
+	second := writeModel(t, dir, "model-00002-of-00002.gguf", "part two")
+
+	alias, err := AddAlias(ctx, "split", first, StoreOptions{Move: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, file := range alias.Files {
+		names = append(names, filepath.Base(file))
+	}
+	if want := []string{"model-00001-of-00002.gguf", "model-00002-of-00002.gguf"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("alias files = %q, want %q", names, want)
+	}
+	if len(blobNames(t, ctx)) != 2 || alias.Size != int64(len("part one")+len("part two")) {
+		t.Errorf("blobs %v, size %d", blobNames(t, ctx), alias.Size)
+	}
+	if utils.FileExists(first) || utils.FileExists(second) {
+		t.Error("--move left the originals behind")
+	}
+
+	// Every shard must be present
+	third := writeModel(t, dir, "other-00001-of-00002.gguf", "part one")
+	if _, err := AddAlias(ctx, "other", third, StoreOptions{}); err == nil {
+		t.Error("stored a split model with a missing shard")
+	}
+}
+
+func TestAddAliasMoveBlob(t *testing.T) {
+	ctx := testContext(t)
+	model := writeModel(t, t.TempDir(), "model.gguf", "weights")
+
+	first, err := AddAlias(ctx, "first", model, StoreOptions{Move: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving a file that is already in the store must leave the blob for
+	// the alias that has it
+	second, err := AddAlias(ctx, "second", first.Files[0], StoreOptions{Move: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(second.Blobs, first.Blobs) {
+		t.Errorf("second alias uses %v, want %v", second.Blobs, first.Blobs)
+	}
+	for _, alias := range []*Alias{first, second} {
+		if data, err := os.ReadFile(alias.Files[0]); err != nil || string(data) != "weights" {
+			t.Errorf("alias %s reads %q, %v", alias.Name, data, err)
+		}
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	ctx := testContext(t)
+	dir := t.TempDir()
+	kept, err := AddAlias(ctx, "kept", writeModel(t, dir, "kept.gguf", "kept"), StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, err := AddAlias(ctx, "dropped", writeModel(t, dir, "dropped.gguf", "dropped"), StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftover := filepath.Join(utils.StoreBlobsDir(ctx), blobPrefix+"ffff.tmp")
+	writeModel(t, utils.StoreBlobsDir(ctx), filepath.Base(leftover), "partial")
+
+	if err := RemoveAlias(ctx, "dropped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveAlias(ctx, "dropped"); err == nil {
+		t.Error("removed a missing alias")
+	}
+
+	removed, freed, err := CollectGarbage(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{dropped.Blobs[0], leftover}
+	if !reflect.DeepEqual(removed, want) || freed != int64(len("dropped")+len("partial")) {
+		t.Errorf("dry run would remove %q (%d bytes), want %q", removed, freed, want)
+	}
+	if len(blobNames(t, ctx)) != 3 {
+		t.Fatal("dry run removed files")
+	}
+
+	if _, _, err := CollectGarbage(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if names := blobNames(t, ctx); !reflect.DeepEqual(names, []string{filepath.Base(kept.Blobs[0])}) {
+		t.Errorf("blobs after gc = %q, want only the kept alias's", names)
+	}
+	if data, err := os.ReadFile(kept.Files[0]); err != nil || string(data) != "kept" {
+		t.Errorf("kept alias reads %q, %v", data, err)
+	}
+}
+
+func TestRemoveUnusedBlobs(t *testing.T) {
+	ctx := testContext(t)
+	dir := t.TempDir()
+	shared := writeModel(t, dir, "shared.gguf", "shared")
+	if _, err := AddAlias(ctx, "one", shared, StoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeModel(t, dir, "two-00002-of-00002.gguf", "own")
+	two, err := AddAlias(ctx, "two", writeModel(t, dir, "two-00001-of-00002.gguf", "shared"), StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, err := AddAlias(ctx, "unrelated", writeModel(t, dir, "unrelated.gguf", "unrelated"), StoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveAlias(ctx, "unrelated"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the blob that nothing else uses goes; the shared one stays for
+	// alias one, and the unrelated one is left to CollectGarbage
+	if err := RemoveAlias(ctx, "two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveUnusedBlobs(ctx, two.Blobs); err != nil {
+		t.Fatal(err)
+	}
+	if !utils.FileExists(two.Blobs[0]) || utils.FileExists(two.Blobs[1]) || !utils.FileExists(unrelated.Blobs[0]) {
+		t.Errorf("blobs after removing alias two: %q", blobNames(t, ctx))
+	}
+
+	// Blobs already gone are fine
+	if err := RemoveUnusedBlobs(ctx, two.Blobs); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,44 +3,72 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github/llamarunner/commands"
+	"github/llamarunner/utils"
 )
 
 func main() {
 	// Initialize commands - now automatic via init functions
 	initializeCommands()
 
-	if len(os.Args) < 2 {
+	run(os.Args[1:])
+}
+
+// run dispatches the command line, without the program name, to a command
+func run(cmdArgs []string) {
+	// Strip global --set key=value overrides that precede the command
+	args, overrides, err := parseOverrides(cmdArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Load settings once and hand them to every command. Commands that can
+	// repair broken settings run with the defaults instead.
+	ctx, err := loadContext(overrides)
+	if err != nil {
+		if ctx == nil || !runsWithoutSettings(args) {
+			fmt.Printf("Error loading settings: %v\n", err)
+			if ctx != nil {
+				fmt.Println("Fix them with 'llamarunner set e', or run 'llamarunner update' if they were written by a newer version.")
+			}
+			return
+		}
+		fmt.Printf("Warning: using default settings: %v\n", err)
+	}
+
+	if len(args) < 1 {
 		cmd, exists := commands.GetCommand("help")
 		if !exists {
 			fmt.Println("Error: help command not found")
 			return
 		}
-		cmd.Run(nil)
+		cmd.Run(ctx, nil)
 		return
 	}
 
 	// Check for -h flag
-	if os.Args[1] == "-h" || os.Args[1] == "--help" {
+	if args[0] == "-h" || args[0] == "--help" {
 		cmd, exists := commands.GetCommand("help")
 		if !exists {
 			fmt.Println("Error: help command not found")
 			return
 		}
-		cmd.Run(nil)
+		cmd.Run(ctx, nil)
 		return
 	}
 
-	commandName := os.Args[1]
+	commandName := args[0]
 	cmd, exists := commands.GetCommand(commandName)
 
 	if !exists {
 		// If it's just a preset name, run it with the run command
-		if len(os.Args) >= 2 {
+		if len(args) >= 1 {
 			runCmd, exists := commands.GetCommand("run")
 			if exists {
-				runCmd.Run([]string{os.Args[1]})
+				runCmd.Run(ctx, []string{args[0]})
 				return
 			}
 		}
@@ -49,7 +77,7 @@ func main() {
 	}
 
 	// Check if any argument is -h or --help
-	for _, arg := range os.Args[2:] {
+	for _, arg := range args[1:] {
 		if arg == "-h" || arg == "--help" {
 			fmt.Printf("%s - %s\n", cmd.Name(), cmd.Description())
 			fmt.Println("Usage: " + cmd.Usage())
@@ -57,7 +85,77 @@ func main() {
 		}
 	}
 
-	cmd.Run(os.Args[2:])
+	cmd.Run(ctx, args[1:])
+}
+
+// recoveryCommands still run when the settings can't be loaded, so the
+// user can get help, update llamarunner or fix the settings file
+var recoveryCommands = map[string]bool{
+	"help":   true,
+	"-h":     true,
+	"--help": true,
+	"update": true,
+	"set":    true,
+}
+
+// runsWithoutSettings reports whether args run a recovery command or only
+// ask for help; running without a command shows help too
+func runsWithoutSettings(args []string) bool {
+	if len(args) == 0 || recoveryCommands[args[0]] {
+		return true
+	}
+	for _, arg := range args[1:] {
+		if arg == "-h" || arg == "--help" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadContext resolves the home and working directories and loads settings
+func loadContext(overrides map[string]string) (*utils.Context, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		workDir = ""
+	}
+
+	return utils.NewContext(home, workDir, overrides)
+}
+
+// parseOverrides collects leading "--set key=value" (or "--set=key=value")
+// options and returns the remaining arguments
+func parseOverrides(args []string) ([]string, map[string]string, error) {
+	overrides := map[string]string{}
+
+	for len(args) > 0 {
+		var pair string
+		switch {
+		case args[0] == "--set":
+			if len(args) < 2 {
+				return nil, nil, fmt.Errorf("--set requires a key=value argument")
+			}
+			pair = args[1]
+			args = args[2:]
+		case strings.HasPrefix(args[0], "--set="):
+			pair = strings.TrimPrefix(args[0], "--set=")
+			args = args[1:]
+		default:
+			return args, overrides, nil
+		}
+
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, nil, fmt.Errorf("invalid --set argument %q, expected key=value", pair)
+		}
+		overrides[key] = value
+	}
+
+	return args, overrides, nil
 }
 
 // initializeCommands now just ensures all commands are registered via their init functions
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github/llamarunner/utils"
+)
+
+// setupHome points HOME at a temporary directory holding the given user
+// settings file and returns the file's path
+func setupHome(t *testing.T, settings string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(name, "")
+	}
+	t.Chdir(t.TempDir())
+
+	path := filepath.Join(home, ".config", "llamarunner", "settings.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// capture runs the command line and returns what it printed
+func capture(t *testing.T, args ...string) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	run(args)
+	w.Close()
+	return <-output
+}
+
+func TestBrokenSettings(t *testing.T) {
+	broken := []struct {
+		name     string
+		settings string
+		fixed    string
+	}{
+		{"newer schema", "schema_version = 99\n", "schema_version = 4\n"},
+		{"unknown key", "schema_version = 4\nno_such_key = 1\n", "schema_version = 4\n"},
+	}
+
+	for _, tt := range broken {
+		t.Run(tt.name, func(t *testing.T) {
+			path := setupHome(t, tt.settings)
+
+			// Commands that need settings refuse to run on the defaults
+			for _, args := range [][]string{{"list"}, {"--set", "port=8081", "list"}} {
+				output := capture(t, args...)
+				if !strings.Contains(output, "Error loading settings") {
+					t.Errorf("%v printed %q, want a load error", args, output)
+				}
+			}
+
+			// Help, and help for any command, still work
+			for _, args := range [][]string{{}, {"help"}, {"--help"}, {"list", "-h"}} {
+				output := capture(t, args...)
+				if !strings.Contains(output, "Warning: using default settings") || strings.Contains(output, "Error loading settings") {
+					t.Errorf("%v printed %q, want a warning", args, output)
+				}
+			}
+
+			// Saving would write the defaults over the file, so it must
+			// fail and leave the file alone
+			ctx, err := loadContext(nil)
+			if err == nil || ctx == nil {
+				t.Fatalf("loadContext = %v, %v; want defaults and an error", ctx, err)
+			}
+			if err := utils.SaveSettings(ctx); err == nil {
+				t.Error("SaveSettings succeeded without loaded settings")
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.settings {
+				t.Fatalf("settings file changed to %q", data)
+			}
+
+			// Editing the file repairs it
+			editor := filepath.Join(t.TempDir(), "editor")
+			script := "#!/bin/sh\nprintf '" + strings.ReplaceAll(tt.fixed, "\n", `\n`) + "' > \"$1\"\n"
+			if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("VISUAL", editor)
+			output := capture(t, "set", "e")
+			if !strings.Contains(output, "Settings saved successfully!") {
+				t.Fatalf("set e printed %q", output)
+			}
+
+			output = capture(t, "list")
+			if strings.Contains(output, "Error loading settings") || strings.Contains(output, "Warning") {
+				t.Errorf("list after repair printed %q", output)
+			}
+		})
+	}
+}
+
+func TestRunsWithoutSettings(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"help"}, true},
+		{[]string{"-h"}, true},
+		{[]string{"update", "--check"}, true},
+		{[]string{"set", "e"}, true},
+		{[]string{"build", "--help"}, true},
+		{[]string{"list"}, false},
+		{[]string{"run", "preset"}, false},
+		{[]string{"preset"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := runsWithoutSettings(tt.args); got != tt.want {
+			t.Errorf("runsWithoutSettings(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checkoutsFile lists every llama.cpp checkout built by llamarunner, so
+// builds outside the configured checkout can still be found
+const checkoutsFile = "checkouts.json"
+
+// RecordCheckout remembers a llama.cpp checkout that was built
+func RecordCheckout(ctx *Context, dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	dirs := recordedCheckouts(ctx)
+	for _, known := range dirs {
+		if known == abs {
+			return nil
+		}
+	}
+	dirs = append(dirs, abs)
+	sort.Strings(dirs)
+
+	data, err := json.MarshalIndent(dirs, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(ctx.Dirs.State, 0755)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(ctx.Dirs.State, checkoutsFile), data, 0644)
+}
+
+// recordedCheckouts returns the checkouts saved by RecordCheckout
+func recordedCheckouts(ctx *Context) []string {
+	data, err := os.ReadFile(filepath.Join(ctx.Dirs.State, checkoutsFile))
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	if json.Unmarshal(data, &dirs) != nil {
+		return nil
+	}
+	return dirs
+}
+
+// KnownCheckouts returns the existing llama.cpp checkouts: the configured
+// one first, then those recorded by builds and any ~/llama.cpp* directory
+func KnownCheckouts(ctx *Context) []string {
+	candidates := []string{ctx.Dirs.ExpandPath(FindLlamaCppDir(ctx))}
+	candidates = append(candidates, recordedCheckouts(ctx)...)
+	if matches, err := filepath.Glob(filepath.Join(ctx.Dirs.Home, "llama.cpp*")); err == nil {
+		candidates = append(candidates, matches...)
+	}
+
+	seen := map[string]bool{}
+	var dirs []string
+	for _, dir := range candidates {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if seen[dir] || !isCheckout(dir) {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// isCheckout reports whether dir looks like a llama.cpp source tree
+func isCheckout(dir string) bool {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	return FileExists(filepath.Join(dir, "CMakeLists.txt")) || FileExists(filepath.Join(dir, "build"))
+}
+
+// CheckoutBuildDirs returns the CMake build directories inside a checkout,
+// such as build/ or build-cuda/
+func CheckoutBuildDirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var builds []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "build") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if entry.Name() == "build" || FileExists(filepath.Join(path, "CMakeCache.txt")) {
+			builds = append(builds, path)
+		}
+	}
+	return builds
+}
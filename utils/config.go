@@ -0,0 +1,345 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Configuration layers, from lowest to highest precedence
+const (
+	OriginDefault     = "default"
+	OriginSystem      = "system"
+	OriginUser        = "user"
+	OriginProject     = "project"
+	OriginEnvironment = "env"
+	OriginCommandLine = "command line"
+)
+
+const (
+	// SystemSettingsFile holds machine-wide settings shared by all users
+	SystemSettingsFile = "/etc/llamarunner/settings.toml"
+
+	// ProjectSettingsFile is looked up from the working directory upwards
+	ProjectSettingsFile = ".llamarunner.toml"
+
+	// EnvPrefix is prepended to the upper-cased setting key, e.g. LLAMARUNNER_PORT
+	EnvPrefix = "LLAMARUNNER_"
+)
+
+// pathSettings are resolved relative to the project file that sets them
+var pathSettings = map[string]bool{
+	"llama_cpp_path": true,
+	"model_path":     true,
+	"config_path":    true,
+}
+
+// SettingOrigin records which layer provided the effective value of a setting
+type SettingOrigin struct {
+	Source string
+	Path   string // settings file or environment variable, if any
+}
+
+// String formats the origin as "source" or "source:path"
+func (o SettingOrigin) String() string {
+	if o.Path == "" {
+		return o.Source
+	}
+	return o.Source + ":" + o.Path
+}
+
+// loadLayeredSettings merges all configuration layers field by field and
+// reports which layer each value came from
+func loadLayeredSettings(ctx *Context) (*Settings, map[string]SettingOrigin, error) {
+	dirs := ctx.Dirs
+
+	// Create the user settings file on first use
+	userFile := getUserSettingsFile(dirs)
+	if !FileExists(userFile) {
+		if _, err := createDefaultSettings(dirs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Upgrade an older user file in place before reading it
+	err := migrateSettingsFile(dirs, userFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings := defaultSettings(dirs)
+	origins := map[string]SettingOrigin{}
+	for _, key := range SettingKeys() {
+		origins[key] = SettingOrigin{Source: OriginDefault}
+	}
+
+	err = mergeSettingsFile(dirs, settings, origins, SystemSettingsFile, OriginSystem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = mergeSettingsFile(dirs, settings, origins, userFile, OriginUser)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if projectFile := findProjectSettingsFile(ctx.WorkDir); projectFile != "" {
+		err = mergeSettingsFile(dirs, settings, origins, projectFile, OriginProject)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, key := range SettingKeys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err = setSettingFromString(settings, key, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+		origins[key] = SettingOrigin{Source: OriginEnvironment, Path: name}
+	}
+
+	for key, value := range ctx.Overrides {
+		err = setSettingFromString(settings, key, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for --set %s: %v", key, err)
+		}
+		origins[key] = SettingOrigin{Source: OriginCommandLine}
+	}
+
+	// Path settings may use ~ or environment variables
+	for key := range pathSettings {
+		field := settingField(settings, key)
+		field.SetString(dirs.ExpandPath(field.String()))
+	}
+
+	return settings, origins, nil
+}
+
+// SaveSettings writes changed settings in ctx to the user's settings file,
+// leaving values inherited from other layers where they are
+func SaveSettings(ctx *Context) error {
+	// Saving the defaults would overwrite the file that failed to load
+	if ctx.LoadErr != nil {
+		return fmt.Errorf("settings were not loaded (%v); fix them with 'llamarunner set e'", ctx.LoadErr)
+	}
+
+	userFile := getUserSettingsFile(ctx.Dirs)
+
+	// Start from the user's own file so unrelated keys are preserved as written
+	tree, err := toml.TreeFromMap(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if data, err := os.ReadFile(userFile); err == nil {
+		tree, err = toml.LoadBytes(data)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %v", userFile, err)
+		}
+	}
+
+	for _, key := range SettingKeys() {
+		if key == "schema_version" {
+			continue
+		}
+
+		value := settingField(ctx.Settings, key).Interface()
+		unchanged := reflect.DeepEqual(value, settingField(&ctx.loaded, key).Interface())
+		source := ctx.Origins[key].Source
+
+		// Keep the user's raw value, e.g. an unexpanded $HOME
+		if unchanged && tree.Has(key) {
+			continue
+		}
+
+		// Don't copy values from the system, project, env or command line
+		if unchanged && source != OriginDefault && source != OriginUser {
+			continue
+		}
+
+		tree.Set(key, value)
+	}
+	tree.Set("schema_version", int64(CurrentSchemaVersion))
+
+	data, err := tree.Marshal()
+	if err != nil {
+		return err
+	}
+
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	err = WriteFileAtomic(userFile, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	ctx.loaded = *ctx.Settings
+	return nil
+}
+
+// SettingKeys returns the TOML keys of all settings in declaration order
+func SettingKeys() []string {
+	settingsType := reflect.TypeOf(Settings{})
+	keys := make([]string, 0, settingsType.NumField())
+	for i := 0; i < settingsType.NumField(); i++ {
+		keys = append(keys, settingsType.Field(i).Tag.Get("toml"))
+	}
+	return keys
+}
+
+// GetSettingValue returns the value of the setting with the given TOML key
+func GetSettingValue(settings *Settings, key string) interface{} {
+	return settingField(settings, key).Interface()
+}
+
+// mergeSettingsFile overlays the keys present in path onto settings
+func mergeSettingsFile(dirs Dirs, settings *Settings, origins map[string]SettingOrigin, path, source string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tree, layer, err := parseSettingsTree(dirs, data)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	for _, key := range tree.Keys() {
+		// The schema version describes the file, not the effective settings
+		if key == "schema_version" {
+			continue
+		}
+
+		field := settingField(layer, key)
+
+		// Relative paths in a project file are relative to that file
+		if source == OriginProject && pathSettings[key] {
+			value := dirs.ExpandPath(field.String())
+			if value != "" && !filepath.IsAbs(value) {
+				field.SetString(filepath.Join(filepath.Dir(path), value))
+			}
+		}
+
+		settingField(settings, key).Set(field)
+		origins[key] = SettingOrigin{Source: source, Path: path}
+	}
+
+	return nil
+}
+
+// findProjectSettingsFile walks up from dir looking for a project settings
+// file
+func findProjectSettingsFile(dir string) string {
+	if dir == "" {
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectSettingsFile)
+		if FileExists(candidate) {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseSettingsTree parses TOML data, upgrading older schema versions in
+// memory and rejecting keys Settings doesn't know
+func parseSettingsTree(dirs Dirs, data []byte) (*toml.Tree, *Settings, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = migrateSettingsTree(dirs, tree)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = checkSettingKeys(tree)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var settings Settings
+	err = tree.Unmarshal(&settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tree, &settings, nil
+}
+
+// checkSettingKeys rejects keys Settings doesn't know
+func checkSettingKeys(tree *toml.Tree) error {
+	for _, key := range tree.Keys() {
+		if !isSettingKey(key) {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return nil
+}
+
+// isSettingKey reports whether key is a known setting
+func isSettingKey(key string) bool {
+	for _, known := range SettingKeys() {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+// settingField returns the addressable struct field for a TOML key
+func settingField(settings *Settings, key string) reflect.Value {
+	value := reflect.ValueOf(settings).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("toml") == key {
+			return value.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// setSettingFromString parses a string value into the setting's field type
+func setSettingFromString(settings *Settings, key, value string) error {
+	field := settingField(settings, key)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Kind())
+	}
+
+	return nil
+}
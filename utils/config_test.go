@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testHome points HOME at a temporary directory with no XDG or
+// LLAMARUNNER_ variables set, and writes the user settings file there
+// unless settings is empty. It returns the home and the settings file.
+func testHome(t *testing.T, settings string) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(name, "")
+	}
+	for _, key := range SettingKeys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	path := filepath.Join(home, ".config", "llamarunner", "settings.toml")
+	if settings != "" {
+		writeFile(t, path, settings)
+	}
+	return home, path
+}
+
+// writeFile creates path and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the contents of path
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDefaultSettings(t *testing.T) {
+	home, path := testHome(t, "")
+
+	ctx, err := NewContext(home, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !FileExists(path) {
+		t.Errorf("%s was not created", path)
+	}
+
+	want := defaultSettings(ctx.Dirs)
+	want.LlamaCppPath = filepath.Join(home, "llama.cpp")
+	if *ctx.Settings != *want {
+		t.Errorf("settings = %+v, want %+v", *ctx.Settings, *want)
+	}
+	for _, key := range SettingKeys() {
+		if source := ctx.Origins[key].Source; source != OriginDefault && source != OriginUser {
+			t.Errorf("%s comes from %s", key, source)
+		}
+	}
+}
+
+func TestLayering(t *testing.T) {
+	home, userFile := testHome(t, `schema_version = 4
+host = "0.0.0.0"
+port = "9000"
+model_path = "$HOME/models"
+force_cpu = true
+`)
+
+	project := filepath.Join(t.TempDir(), "project")
+	workDir := filepath.Join(project, "sub", "dir")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectFile := filepath.Join(project, ProjectSettingsFile)
+	writeFile(t, projectFile, "port = \"9100\"\nconfig_path = \"presets\"\n")
+
+	t.Setenv("LLAMARUNNER_PORT", "9200")
+	t.Setenv("LLAMARUNNER_HUB_URL", "https://hub.example")
+
+	ctx, err := NewContext(home, workDir, map[string]string{"hub_url": "https://mirror.example", "force_cpu": "false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin SettingOrigin
+	}{
+		{"host", "0.0.0.0", SettingOrigin{OriginUser, userFile}},
+		{"model_path", filepath.Join(home, "models"), SettingOrigin{OriginUser, userFile}},
+		{"config_path", filepath.Join(project, "presets"), SettingOrigin{OriginProject, projectFile}},
+		{"port", "9200", SettingOrigin{OriginEnvironment, "LLAMARUNNER_PORT"}},
+		{"hub_url", "https://mirror.example", SettingOrigin{OriginCommandLine, ""}},
+		{"force_cpu", false, SettingOrigin{OriginCommandLine, ""}},
+		{"build_profile", "", SettingOrigin{OriginDefault, ""}},
+	}
+
+	for _, tt := range tests {
+		if got := GetSettingValue(ctx.Settings, tt.key); got != tt.value {
+			t.Errorf("%s = %v, want %v", tt.key, got, tt.value)
+		}
+		if got := ctx.Origins[tt.key]; got != tt.origin {
+			t.Errorf("origin of %s = %v, want %v", tt.key, got, tt.origin)
+		}
+	}
+
+	// Saving a change keeps the user's raw values and doesn't copy values
+	// from the project, environment or command line into the user file
+	ctx.Settings.Host = "127.0.0.1"
+	if err := SaveSettings(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saved := readFile(t, userFile)
+	for _, want := range []string{`host = "127.0.0.1"`, `port = "9000"`, `model_path = "$HOME/models"`, "force_cpu = true"} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("saved settings lack %s:\n%s", want, saved)
+		}
+	}
+	for _, unwanted := range []string{"9200", "example", "presets"} {
+		if strings.Contains(saved, unwanted) {
+			t.Errorf("saved settings contain %s:\n%s", unwanted, saved)
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	original := "model_path = \"$HOME/.config/llamarunner/settings.toml\"\nport = \"9000\"\n"
+	home, path := testHome(t, original)
+
+	ctx, err := NewContext(home, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Settings.ModelPath != ctx.Dirs.Models() {
+		t.Errorf("model_path = %s, want %s", ctx.Settings.ModelPath, ctx.Dirs.Models())
+	}
+	if ctx.Settings.Port != "9000" {
+		t.Errorf("port = %s, want 9000", ctx.Settings.Port)
+	}
+
+	if backup := readFile(t, path+".v1.bak"); backup != original {
+		t.Errorf("backup = %q, want %q", backup, original)
+	}
+	migrated := readFile(t, path)
+	if !strings.Contains(migrated, "schema_version = 4") || strings.Contains(migrated, "settings.toml") {
+		t.Errorf("migrated file:\n%s", migrated)
+	}
+
+	// A current file is left alone
+	if _, err := NewContext(home, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, path) != migrated {
+		t.Error("a current file was rewritten")
+	}
+}
+
+func TestRejectedSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  string
+		project   string
+		env       string
+		overrides map[string]string
+		want      string
+	}{
+		{name: "newer schema", settings: "schema_version = 5\n", want: "llamarunner update"},
+		{name: "invalid schema version", settings: "schema_version = \"four\"\n", want: "invalid schema_version"},
+		{name: "unknown key", settings: "schema_version = 4\ncolor = true\n", want: `unknown setting "color"`},
+		{name: "unknown key in an old file", settings: "colour = true\n", want: `unknown setting "colour"`},
+		{name: "unknown key in the project", settings: "schema_version = 4\n", project: "colour = true\n", want: ProjectSettingsFile},
+		{name: "wrong type", settings: "schema_version = 4\nforce_cpu = \"yes\"\n", want: "error parsing"},
+		{name: "invalid environment value", settings: "schema_version = 4\n", env: "maybe", want: "LLAMARUNNER_FORCE_CPU"},
+		{name: "invalid override", settings: "schema_version = 4\n", overrides: map[string]string{"force_cpu": "maybe"}, want: "--set force_cpu"},
+		{name: "unknown override", settings: "schema_version = 4\n", overrides: map[string]string{"colour": "red"}, want: `unknown setting "colour"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, path := testHome(t, tt.settings)
+			workDir := t.TempDir()
+			if tt.project != "" {
+				writeFile(t, filepath.Join(workDir, ProjectSettingsFile), tt.project)
+			}
+			if tt.env != "" {
+				t.Setenv("LLAMARUNNER_FORCE_CPU", tt.env)
+			}
+
+			ctx, err := NewContext(home, workDir, tt.overrides)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+			if readFile(t, path) != tt.settings {
+				t.Errorf("settings file changed to %q", readFile(t, path))
+			}
+
+			// Loading falls back to the defaults, which are never saved
+			if ctx == nil {
+				return
+			}
+			if ctx.LoadErr == nil || *ctx.Settings != *defaultSettings(ctx.Dirs) {
+				t.Errorf("fallback settings = %+v, error %v", *ctx.Settings, ctx.LoadErr)
+			}
+			if err := SaveSettings(ctx); err == nil {
+				t.Error("SaveSettings succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// Context carries the settings loaded once at startup, and the directories
+// they were loaded from, to every command and helper
+type Context struct {
+	// Settings holds the effective, merged settings
+	Settings *Settings
+
+	// Origins records which layer provided each setting
+	Origins map[string]SettingOrigin
+
+	// Dirs holds the home and XDG directories in use
+	Dirs Dirs
+
+	// WorkDir is where project settings are looked up from
+	WorkDir string
+
+	// Overrides holds key=value pairs given with --set
+	Overrides map[string]string
+
+	// LoadErr is why the settings couldn't be loaded when the built-in
+	// defaults are in use instead; settings aren't saved then
+	LoadErr error
+
+	// loaded is the merged result as read, so SaveSettings only persists
+	// values that were actually changed
+	loaded Settings
+}
+
+// NewContext resolves the directories for home, migrates legacy files and
+// loads the layered settings once. When the settings files can't be loaded
+// it returns the error together with a context using the built-in defaults,
+// so commands that repair the settings can still run.
+func NewContext(home, workDir string, overrides map[string]string) (*Context, error) {
+	for key := range overrides {
+		if !isSettingKey(key) {
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+
+	ctx := &Context{
+		Dirs:      NewDirs(home, os.Getenv),
+		WorkDir:   workDir,
+		Overrides: overrides,
+	}
+
+	// Move ~/.llama-presets over once; on failure keep using it as is
+	err := MigrateLegacyDir(ctx.Dirs)
+	if err != nil {
+		fmt.Printf("Warning: could not migrate %s: %v\n", ctx.Dirs.Legacy(), err)
+	}
+
+	err = ctx.Reload()
+	if err != nil {
+		ctx.Settings = defaultSettings(ctx.Dirs)
+		ctx.Origins = map[string]SettingOrigin{}
+		for _, key := range SettingKeys() {
+			ctx.Origins[key] = SettingOrigin{Source: OriginDefault}
+		}
+		ctx.loaded = *ctx.Settings
+		ctx.LoadErr = err
+		return ctx, err
+	}
+
+	return ctx, nil
+}
+
+// Reload re-reads all configuration layers into the context
+func (ctx *Context) Reload() error {
+	settings, origins, err := loadLayeredSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.Settings = settings
+	ctx.Origins = origins
+	ctx.loaded = *settings
+	ctx.LoadErr = nil
+	return nil
+}
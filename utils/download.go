@@ -0,0 +1,513 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Files at least this large are fetched in parallel chunks when the server
+// supports range requests
+const parallelDownloadMin = 64 << 20
+
+// progressInterval is how often the progress line is redrawn
+const progressInterval = 500 * time.Millisecond
+
+// DownloadOptions controls how DownloadFile fetches and verifies a file
+type DownloadOptions struct {
+	// Size is the expected size in bytes, or 0 if unknown
+	Size int64
+
+	// SHA256 is the expected hex digest; empty skips verification
+	SHA256 string
+
+	// Connections is the number of parallel range requests for large files
+	Connections int
+
+	// Header is sent with every request, e.g. for authorization
+	Header http.Header
+
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// StatusError is returned when the server answers with an unexpected status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status from %s: %s", e.URL, e.Status)
+}
+
+// downloadChunk is a byte range of a parallel download and how much of it
+// has been written
+type downloadChunk struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"` // inclusive
+	Done  int64 `json:"done"`
+}
+
+// downloadState is saved next to the .part file so parallel downloads can
+// resume
+type downloadState struct {
+	URL    string          `json:"url"`
+	Size   int64           `json:"size"`
+	Chunks []downloadChunk `json:"chunks"`
+}
+
+// DownloadFile downloads url to dest. Data is written to dest.part first and
+// an interrupted download resumes from there. The file is only renamed into
+// place once its size and sha256 match the expected values.
+func DownloadFile(url, dest string, opts DownloadOptions) error {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	part := dest + ".part"
+	statePath := part + ".json"
+
+	parallel := opts.Connections > 1 && opts.Size >= parallelDownloadMin
+	if parallel && !FileExists(statePath) && FileExists(part) {
+		// A sequential download was interrupted; keep appending to it
+		parallel = false
+	}
+	if parallel {
+		parallel, err = supportsRanges(url, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if parallel {
+		err = downloadParallel(url, part, statePath, opts)
+	} else {
+		err = downloadSequential(url, part, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = verifyDownload(part, opts)
+	if err != nil {
+		os.Remove(part)
+		os.Remove(statePath)
+		return fmt.Errorf("%v; the download was discarded, run again to start over", err)
+	}
+
+	os.Remove(statePath)
+	return os.Rename(part, dest)
+}
+
+// newDownloadRequest builds a GET request with the configured headers
+func newDownloadRequest(url string, opts DownloadOptions) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range opts.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req, nil
+}
+
+// supportsRanges asks for the first byte of url to see whether the server
+// honours range requests
+func supportsRanges(url string, opts DownloadOptions) (bool, error) {
+	req, err := newDownloadRequest(url, opts)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return true, nil
+	case http.StatusOK:
+		return false, nil
+	}
+	return false, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// downloadSequential fetches url into part with a single request, resuming
+// from the end of an existing part file
+func downloadSequential(url, part string, opts DownloadOptions) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	if opts.Size > 0 && offset == opts.Size {
+		return nil
+	}
+	if opts.Size > 0 && offset > opts.Size {
+		// Left over from a different file; start again
+		offset = 0
+	}
+
+	req, err := newDownloadRequest(url, opts)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+		fmt.Printf("Resuming at %s\n", FormatBytes(offset))
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, so the whole file is coming
+		flags |= os.O_TRUNC
+		offset = 0
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Nothing left to fetch; verification decides if the file is good
+		return nil
+	default:
+		return &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	total := opts.Size
+	if total == 0 && resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	progress := newProgress(filepath.Base(strings.TrimSuffix(part, ".part")), total, offset)
+	defer progress.Stop()
+
+	_, err = io.Copy(out, io.TeeReader(resp.Body, progress))
+	if err != nil {
+		return fmt.Errorf("download interrupted at %s, run again to resume: %v", FormatBytes(progress.Done()), err)
+	}
+	return out.Close()
+}
+
+// downloadParallel fetches url into part using several range requests.
+// Chunk progress is saved to statePath so an interrupted download resumes.
+func downloadParallel(url, part, statePath string, opts DownloadOptions) error {
+	state, err := loadDownloadState(statePath, url, opts.Size)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = newDownloadState(url, opts.Size, opts.Connections)
+	}
+
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	err = out.Truncate(opts.Size)
+	if err != nil {
+		return err
+	}
+
+	var done int64
+	for _, chunk := range state.Chunks {
+		done += chunk.Done
+	}
+	if done > 0 && done < opts.Size {
+		fmt.Printf("Resuming at %s\n", FormatBytes(done))
+	}
+
+	progress := newProgress(filepath.Base(strings.TrimSuffix(part, ".part")), opts.Size, done)
+	defer progress.Stop()
+
+	var mu sync.Mutex
+	saveState := func() error {
+		mu.Lock()
+		data, err := json.Marshal(state)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(statePath, data, 0644)
+	}
+
+	err = saveState()
+	if err != nil {
+		return err
+	}
+
+	// Save chunk progress periodically while the workers run
+	stop := make(chan struct{})
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				saveState()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	errs := make(chan error, len(state.Chunks))
+	var wg sync.WaitGroup
+	for i := range state.Chunks {
+		wg.Add(1)
+		go func(chunk *downloadChunk) {
+			defer wg.Done()
+			errs <- downloadChunkRange(url, out, chunk, &mu, progress, opts)
+		}(&state.Chunks[i])
+	}
+	wg.Wait()
+	close(errs)
+
+	close(stop)
+	<-saved
+
+	saveErr := saveState()
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("download interrupted at %s, run again to resume: %v", FormatBytes(progress.Done()), err)
+		}
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+
+	return out.Sync()
+}
+
+// downloadChunkRange fetches the remaining bytes of one chunk
+func downloadChunkRange(url string, out *os.File, chunk *downloadChunk, mu *sync.Mutex, progress *progress, opts DownloadOptions) error {
+	mu.Lock()
+	offset := chunk.Start + chunk.Done
+	mu.Unlock()
+	if offset > chunk.End {
+		return nil
+	}
+
+	req, err := newDownloadRequest(url, opts)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, chunk.End))
+
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	buf := make([]byte, 256<<10)
+	for offset <= chunk.End {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if int64(n) > chunk.End-offset+1 {
+				n = int(chunk.End - offset + 1)
+			}
+			_, werr := out.WriteAt(buf[:n], offset)
+			if werr != nil {
+				return werr
+			}
+			offset += int64(n)
+			progress.Write(buf[:n])
+
+			mu.Lock()
+			chunk.Done = offset - chunk.Start
+			mu.Unlock()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset <= chunk.End {
+		return fmt.Errorf("short read for bytes %d-%d", chunk.Start, chunk.End)
+	}
+	return nil
+}
+
+// newDownloadState splits size bytes into one chunk per connection
+func newDownloadState(url string, size int64, connections int) *downloadState {
+	state := &downloadState{URL: url, Size: size}
+
+	chunkSize := size / int64(connections)
+	for i := 0; i < connections; i++ {
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+		if i == connections-1 {
+			end = size - 1
+		}
+		state.Chunks = append(state.Chunks, downloadChunk{Start: start, End: end})
+	}
+	return state
+}
+
+// loadDownloadState reads saved chunk progress, returning nil when there is
+// none or it belongs to a different download
+func loadDownloadState(path, url string, size int64) (*downloadState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &downloadState{}
+	if json.Unmarshal(data, state) != nil || state.URL != url || state.Size != size {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// verifyDownload checks the size and sha256 of a finished download
+func verifyDownload(path string, opts DownloadOptions) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if opts.Size > 0 && info.Size() != opts.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", opts.Size, info.Size())
+	}
+
+	if opts.SHA256 == "" {
+		return nil
+	}
+
+	fmt.Println("Verifying sha256...")
+	sum, err := HashFile(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, opts.SHA256) {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", opts.SHA256, sum)
+	}
+	return nil
+}
+
+// progress counts downloaded bytes and redraws a status line periodically
+type progress struct {
+	name  string
+	total int64
+	start int64
+	done  atomic.Int64
+	began time.Time
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newProgress starts printing progress for a download that already has
+// start bytes on disk
+func newProgress(name string, total, start int64) *progress {
+	p := &progress{
+		name:  name,
+		total: total,
+		start: start,
+		began: time.Now(),
+		stop:  make(chan struct{}),
+	}
+	p.done.Store(start)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Write implements io.Writer by counting bytes
+func (p *progress) Write(data []byte) (int, error) {
+	p.done.Add(int64(len(data)))
+	return len(data), nil
+}
+
+// Done returns the bytes downloaded so far, including resumed ones
+func (p *progress) Done() int64 {
+	return p.done.Load()
+}
+
+// print redraws the progress line
+func (p *progress) print() {
+	done := p.Done()
+
+	rate := ""
+	if elapsed := time.Since(p.began).Seconds(); elapsed > 0 {
+		rate = FormatBytes(int64(float64(done-p.start)/elapsed)) + "/s"
+	}
+
+	if p.total > 0 {
+		percent := float64(done) * 100 / float64(p.total)
+		fmt.Printf("\r%s  %s / %s (%s%%)  %s   ", p.name, FormatBytes(done), FormatBytes(p.total),
+			strconv.FormatFloat(percent, 'f', 1, 64), rate)
+	} else {
+		fmt.Printf("\r%s  %s  %s   ", p.name, FormatBytes(done), rate)
+	}
+}
+
+// Stop prints the final progress line
+func (p *progress) Stop() {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.print()
+	fmt.Println()
+}
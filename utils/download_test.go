@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves content with range support and records the Range header
+// of every request. The first cutAfter bytes of the first full request are
+// sent before the connection drops, as in an interrupted download.
+type testServer struct {
+	*httptest.Server
+	content  []byte
+	cutAfter int
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+// newTestServer starts a server for content
+func newTestServer(t *testing.T, content []byte, cutAfter int) *testServer {
+	s := &testServer{content: content, cutAfter: cutAfter}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve answers one request
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	cut := s.cutAfter
+	if r.Header.Get("Range") == "" {
+		s.cutAfter = 0
+	}
+	s.mu.Unlock()
+
+	if cut > 0 && r.Header.Get("Range") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		w.Write(s.content[:cut])
+		w.(http.Flusher).Flush()
+		return
+	}
+	http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(s.content))
+}
+
+// requests returns the Range headers seen so far, sorted
+func (s *testServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranges := append([]string(nil), s.ranges...)
+	sort.Strings(ranges)
+	return ranges
+}
+
+// testContent returns size bytes that differ from position to position
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i*7 + i/251)
+	}
+	return content
+}
+
+// sha256Hex returns the hex digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := testContent(200 << 10)
+	server := newTestServer(t, content, 80<<10)
+	dest := filepath.Join(t.TempDir(), "models", "model.gguf")
+	opts := DownloadOptions{Size: int64(len(content)), SHA256: sha256Hex(content), Connections: 4}
+
+	err := DownloadFile(server.URL, dest, opts)
+	if err == nil || !strings.Contains(err.Error(), "run again to resume") {
+		t.Fatalf("interrupted download returned %v", err)
+	}
+	if FileExists(dest) {
+		t.Fatal("an unfinished download was moved into place")
+	}
+	info, err := os.Stat(dest + ".part")
+	if err != nil || info.Size() == 0 || info.Size() >= int64(len(content)) {
+		t.Fatalf("partial file after the interruption: %v, %v", info, err)
+	}
+
+	if err := DownloadFile(server.URL, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded %d bytes (%v), want the original %d", len(got), err, len(content))
+	}
+	if FileExists(dest + ".part") {
+		t.Error(".part file left behind")
+	}
+
+	// Small files are fetched sequentially, so the second request picks up
+	// where the first stopped
+	want := []string{"", "bytes=" + strconv.FormatInt(info.Size(), 10) + "-"}
+	if ranges := server.requests(); strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %q, want %q", ranges, want)
+	}
+}
+
+func TestDownloadParallel(t *testing.T) {
+	content := testContent(100 << 10)
+	size := int64(len(content))
+	server := newTestServer(t, content, 0)
+	dir := t.TempDir()
+	part := filepath.Join(dir, "model.gguf.part")
+	statePath := part + ".json"
+	opts := DownloadOptions{Size: size, Connections: 4, Client: http.DefaultClient}
+
+	if ok, err := supportsRanges(server.URL, opts); !ok || err != nil {
+		t.Fatalf("supportsRanges = %v, %v", ok, err)
+	}
+
+	if err := downloadParallel(server.URL, part, statePath, opts); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(part)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded %d bytes (%v), want the original %d", len(got), err, size)
+	}
+	want := []string{"bytes=0-0", "bytes=0-25599", "bytes=25600-51199", "bytes=51200-76799", "bytes=76800-102399"}
+	if ranges := server.requests(); strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %q, want %q", ranges, want)
+	}
+
+	// Resuming fetches only what the saved state says is missing
+	server.mu.Lock()
+	server.ranges = nil
+	server.mu.Unlock()
+	state := newDownloadState(server.URL, size, 4)
+	state.Chunks[0].Done = state.Chunks[0].End - state.Chunks[0].Start + 1
+	state.Chunks[1].Done = 1000
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	partial := append([]byte(nil), content...)
+	for i := state.Chunks[1].Start + 1000; i < size; i++ {
+		partial[i] = 0
+	}
+	if err := os.WriteFile(part, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadParallel(server.URL, part, statePath, opts); err != nil {
+		t.Fatal(err)
+	}
+	got, err = os.ReadFile(part)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("resumed download differs from the original (%v)", err)
+	}
+	want = []string{"bytes=26600-51199", "bytes=51200-76799", "bytes=76800-102399"}
+	if ranges := server.requests(); strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("resumed requests = %q, want %q", ranges, want)
+	}
+}
+
+func TestDownloadVerification(t *testing.T) {
+	content := testContent(10 << 10)
+	server := newTestServer(t, content, 0)
+
+	tests := []struct {
+		name string
+		opts DownloadOptions
+		want string
+	}{
+		{"matching", DownloadOptions{Size: int64(len(content)), SHA256: strings.ToUpper(sha256Hex(content))}, ""},
+		{"no expectations", DownloadOptions{}, ""},
+		{"sha256 mismatch", DownloadOptions{Size: int64(len(content)), SHA256: sha256Hex([]byte("other"))}, "sha256 mismatch"},
+		{"size mismatch", DownloadOptions{Size: int64(len(content)) - 1}, "size mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "model.gguf")
+			err := DownloadFile(server.URL, dest, tt.opts)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+					t.Error("downloaded file differs from the original")
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+			if FileExists(dest) || FileExists(dest+".part") {
+				t.Error("a rejected download was kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EditSettingsFile opens the settings file in the user's editor and replaces
+// it only once the edited copy parses into valid Settings
+func EditSettingsFile(ctx *Context) {
+	settingsFile := getUserSettingsFile(ctx.Dirs)
+	original, err := os.ReadFile(settingsFile)
+	if err != nil {
+		fmt.Printf("Error reading settings file: %v\n", err)
+		return
+	}
+
+	// Work on a temporary copy so a broken edit never touches the real file
+	tmpFile, err := os.CreateTemp("", "llamarunner-settings-*.toml")
+	if err != nil {
+		fmt.Printf("Error creating temporary file: %v\n", err)
+		return
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmpFile.Write(original)
+	tmpFile.Close()
+	if err != nil {
+		fmt.Printf("Error writing temporary file: %v\n", err)
+		return
+	}
+
+	editor := findEditor()
+	if len(editor) == 0 {
+		fmt.Println("Error: no editor found. Set $VISUAL or $EDITOR, or install nano or vi")
+		fmt.Println("You can manually edit: " + settingsFile)
+		return
+	}
+
+	fmt.Printf("Editing settings file: %s\n", settingsFile)
+
+	for {
+		err = runEditor(editor, tmpPath)
+		if err != nil {
+			fmt.Printf("Error running editor: %v\n", err)
+			return
+		}
+
+		edited, err := os.ReadFile(tmpPath)
+		if err != nil {
+			fmt.Printf("Error reading edited settings: %v\n", err)
+			return
+		}
+
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes made.")
+			return
+		}
+
+		// Validate before saving, offering another round in the editor
+		_, err = ParseSettings(ctx.Dirs, edited)
+		if err != nil {
+			fmt.Printf("Invalid settings: %v\n", err)
+			fmt.Print("Edit again? (Y/n) ")
+
+			var input string
+			fmt.Scanln(&input)
+
+			input = strings.TrimSpace(strings.ToLower(input))
+			if input == "n" {
+				fmt.Println("Changes discarded.")
+				return
+			}
+			continue
+		}
+
+		err = WriteFileAtomic(settingsFile, edited, 0644)
+		if err != nil {
+			fmt.Printf("Error saving settings: %v\n", err)
+			return
+		}
+
+		fmt.Println("Settings saved successfully!")
+
+		err = ctx.Reload()
+		if err != nil {
+			fmt.Printf("Error reloading settings: %v\n", err)
+		}
+		return
+	}
+}
+
+// ParseSettings parses TOML data into Settings, rejecting unknown keys and
+// invalid values
+func ParseSettings(dirs Dirs, data []byte) (*Settings, error) {
+	_, settings, err := parseSettingsTree(dirs, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Port != "" {
+		port, err := strconv.Atoi(settings.Port)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port must be a number between 1 and 65535, got %q", settings.Port)
+		}
+	}
+
+	return settings, nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// findEditor returns the editor command from $VISUAL or $EDITOR, falling back
+// to nano and vi
+func findEditor() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		// The variable may carry arguments, e.g. "code --wait"
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+
+	for _, fallback := range []string{"nano", "vi"} {
+		if isCommandAvailable(fallback) {
+			return []string{fallback}
+		}
+	}
+
+	return nil
+}
+
+// runEditor opens path in the given editor attached to the terminal
+func runEditor(editor []string, path string) error {
+	args := append(editor[1:], path)
+	cmd := exec.Command(editor[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
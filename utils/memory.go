@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github/llamarunner/gguf"
+)
+
+// llama-server defaults used when a preset doesn't set an option
+const (
+	defaultContextSize = 4096
+	defaultBatchSize   = 2048
+	defaultUBatchSize  = 512
+	defaultParallel    = 1
+	defaultCacheType   = "f16"
+)
+
+// cacheTypeBytes is the storage cost per element of each KV cache type
+var cacheTypeBytes = map[string]float64{
+	"f32":    4,
+	"f16":    2,
+	"bf16":   2,
+	"q8_0":   34.0 / 32,
+	"q4_0":   18.0 / 32,
+	"q4_1":   20.0 / 32,
+	"iq4_nl": 18.0 / 32,
+	"q5_0":   22.0 / 32,
+	"q5_1":   24.0 / 32,
+}
+
+// MemoryOptions are the launch options that affect memory use
+type MemoryOptions struct {
+	// ContextSize is the total context across all slots; 0 means the
+	// model's trained context length
+	ContextSize    int
+	BatchSize      int
+	UBatchSize     int
+	Parallel       int
+	GPULayers      int // negative means all layers
+	CacheTypeK     string
+	CacheTypeV     string
+	FlashAttention bool
+}
+
+// MemoryOptionsFromPreset reads memory-related options from a preset,
+// falling back to llama-server's defaults
+func MemoryOptionsFromPreset(preset *Preset) MemoryOptions {
+	opts := MemoryOptions{
+		ContextSize: preset.GetInt("ctx_size", defaultContextSize),
+		BatchSize:   preset.GetInt("batch_size", defaultBatchSize),
+		UBatchSize:  preset.GetInt("ubatch_size", defaultUBatchSize),
+		Parallel:    preset.GetInt("parallel", defaultParallel),
+		GPULayers:   preset.GetInt("n_gpu_layers", 0),
+		CacheTypeK:  defaultCacheType,
+		CacheTypeV:  defaultCacheType,
+	}
+
+	if value, ok := preset.Get("n_gpu_layers"); ok && (value == "all" || value == "auto") {
+		opts.GPULayers = -1
+	}
+	if value, ok := preset.Get("cache_type_k"); ok {
+		opts.CacheTypeK = strings.ToLower(value)
+	}
+	if value, ok := preset.Get("cache_type_v"); ok {
+		opts.CacheTypeV = strings.ToLower(value)
+	}
+	if value, ok := preset.Get("flash_attn"); ok {
+		opts.FlashAttention = value == "true" || value == "on" || value == "1"
+	}
+
+	return opts
+}
+
+// MemoryEstimate is the expected memory use of a model with given options
+type MemoryEstimate struct {
+	Weights int64
+	KVCache int64
+	Compute int64
+
+	// GPUFraction is the share of layers offloaded to the GPU
+	GPUFraction float64
+
+	// ContextSize is the effective total context
+	ContextSize int
+	Parallel    int
+}
+
+// Host returns the bytes expected in system RAM
+func (e MemoryEstimate) Host() int64 {
+	host := float64(e.Weights+e.KVCache) * (1 - e.GPUFraction)
+	if e.GPUFraction < 1 {
+		host += float64(e.Compute)
+	}
+	return int64(host)
+}
+
+// GPU returns the bytes expected in GPU memory
+func (e MemoryEstimate) GPU() int64 {
+	gpu := float64(e.Weights+e.KVCache) * e.GPUFraction
+	if e.GPUFraction >= 1 {
+		gpu += float64(e.Compute)
+	}
+	return int64(gpu)
+}
+
+// EstimateMemory estimates weights, KV cache and compute buffer sizes from a
+// model's metadata. The compute buffer is a rough upper bound.
+func EstimateMemory(model *gguf.Model, opts MemoryOptions) MemoryEstimate {
+	meta := model.Metadata()
+
+	layers, _ := meta.ArchUint("block_count")
+	embedding, _ := meta.ArchUint("embedding_length")
+	feedForward, _ := meta.ArchUint("feed_forward_length")
+	heads, _ := meta.ArchUint("attention.head_count")
+	kvHeads, ok := meta.ArchUint("attention.head_count_kv")
+	if !ok {
+		kvHeads = heads
+	}
+
+	// Head sizes default to embedding / heads
+	var headSize uint64
+	if heads > 0 {
+		headSize = embedding / heads
+	}
+	keySize, ok := meta.ArchUint("attention.key_length")
+	if !ok {
+		keySize = headSize
+	}
+	valueSize, ok := meta.ArchUint("attention.value_length")
+	if !ok {
+		valueSize = headSize
+	}
+
+	var vocab uint64
+	if tokens, ok := meta.GetArray("tokenizer.ggml.tokens"); ok {
+		vocab = uint64(len(tokens.Values))
+	}
+
+	ctxSize := opts.ContextSize
+	if ctxSize <= 0 {
+		trained, _ := meta.ArchUint("context_length")
+		ctxSize = int(trained)
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	ubatch := opts.UBatchSize
+	if ubatch <= 0 || (opts.BatchSize > 0 && ubatch > opts.BatchSize) {
+		ubatch = opts.BatchSize
+	}
+
+	estimate := MemoryEstimate{
+		Weights:     int64(model.TensorDataSize()),
+		ContextSize: ctxSize,
+		Parallel:    parallel,
+	}
+
+	// K and V for every layer and every context position
+	kPerToken := float64(layers*kvHeads*keySize) * cacheBytes(opts.CacheTypeK)
+	vPerToken := float64(layers*kvHeads*valueSize) * cacheBytes(opts.CacheTypeV)
+	estimate.KVCache = int64((kPerToken + vPerToken) * float64(ctxSize))
+
+	// Logits, feed-forward activations and, without flash attention, the
+	// attention score matrix for one micro-batch, all in f32
+	compute := uint64(ubatch) * (vocab + 2*feedForward + 4*embedding) * 4
+	if !opts.FlashAttention {
+		compute += uint64(ubatch) * uint64(ctxSize/parallel) * heads * 4
+	}
+	estimate.Compute = int64(compute)
+
+	// The output layer counts as one more offloadable layer
+	if layers > 0 {
+		offloaded := uint64(opts.GPULayers)
+		if opts.GPULayers < 0 || offloaded > layers+1 {
+			offloaded = layers + 1
+		}
+		estimate.GPUFraction = float64(offloaded) / float64(layers+1)
+	}
+
+	return estimate
+}
+
+// cacheBytes returns the per-element size of a KV cache type
+func cacheBytes(cacheType string) float64 {
+	if size, ok := cacheTypeBytes[cacheType]; ok {
+		return size
+	}
+	return cacheTypeBytes[defaultCacheType]
+}
+
+// MemInfo holds the fields of /proc/meminfo llamarunner cares about, in bytes
+type MemInfo struct {
+	Total     int64
+	Free      int64
+	Available int64
+	SwapFree  int64
+}
+
+// ReadMemInfo parses a /proc/meminfo style file
+func ReadMemInfo(path string) (*MemInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &MemInfo{}
+	fields := map[string]*int64{
+		"MemTotal":     &info.Total,
+		"MemFree":      &info.Free,
+		"MemAvailable": &info.Available,
+		"SwapFree":     &info.SwapFree,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Lines look like "MemAvailable:   12345678 kB"
+		name, rest, found := strings.Cut(scanner.Text(), ":")
+		target, ok := fields[name]
+		if !found || !ok {
+			continue
+		}
+
+		parts := strings.Fields(rest)
+		if len(parts) == 0 {
+			continue
+		}
+		value, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %q", name, parts[0])
+		}
+		if len(parts) > 1 && parts[1] == "kB" {
+			value *= 1024
+		}
+		*target = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Kernels before 3.14 don't report MemAvailable
+	if info.Available == 0 {
+		info.Available = info.Free
+	}
+
+	return info, nil
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"testing"
+
+	"github/llamarunner/gguf"
+)
+
+// modelShape is the metadata EstimateMemory reads
+type modelShape struct {
+	arch        string
+	layers      uint32
+	embedding   uint32
+	feedForward uint32
+	heads       uint32
+	kvHeads     uint32 // 0 leaves head_count_kv unset
+	keyLength   uint32 // 0 leaves key_length and value_length unset
+	context     uint32
+	vocab       int
+}
+
+// Published shapes of a few well-known models
+var (
+	llama2_7b  = modelShape{"llama", 32, 4096, 11008, 32, 0, 0, 4096, 32000}
+	llama3_8b  = modelShape{"llama", 32, 4096, 14336, 32, 8, 0, 8192, 128256}
+	qwen25_7b  = modelShape{"qwen2", 28, 3584, 18944, 28, 4, 0, 32768, 152064}
+	gemma2_9b  = modelShape{"gemma2", 42, 3584, 14336, 16, 8, 256, 8192, 256000}
+	llama3_70b = modelShape{"llama", 80, 8192, 28672, 64, 8, 0, 8192, 128256}
+)
+
+// model returns a single-file model with the shape's metadata and one F16
+// tensor of weightElements elements
+func (s modelShape) model(weightElements uint64) *gguf.Model {
+	u32 := func(key string, value uint32) gguf.KV {
+		return gguf.KV{Key: s.arch + "." + key, Type: gguf.TypeUint32, Value: value}
+	}
+	metadata := []gguf.KV{
+		{Key: "general.architecture", Type: gguf.TypeString, Value: s.arch},
+		u32("block_count", s.layers),
+		u32("embedding_length", s.embedding),
+		u32("feed_forward_length", s.feedForward),
+		u32("attention.head_count", s.heads),
+		u32("context_length", s.context),
+		{Key: "tokenizer.ggml.tokens", Type: gguf.TypeArray, Value: &gguf.Array{Type: gguf.TypeString, Values: make([]interface{}, s.vocab)}},
+	}
+	if s.kvHeads > 0 {
+		metadata = append(metadata, u32("attention.head_count_kv", s.kvHeads))
+	}
+	if s.keyLength > 0 {
+		metadata = append(metadata, u32("attention.key_length", s.keyLength), u32("attention.value_length", s.keyLength))
+	}
+
+	file := &gguf.File{
+		Metadata: metadata,
+		Tensors:  []gguf.TensorInfo{{Name: "weights", Dimensions: []uint64{weightElements}, Type: 1}},
+	}
+	return &gguf.Model{Shards: []*gguf.File{file}}
+}
+
+func TestEstimateKVCache(t *testing.T) {
+	const mib = 1 << 20
+
+	tests := []struct {
+		name     string
+		shape    modelShape
+		ctx      int
+		k, v     string
+		parallel int
+		want     int64
+	}{
+		// Without grouped-query attention every head has its own K and V
+		{"llama 2 7b f16", llama2_7b, 4096, "f16", "f16", 1, 2048 * mib},
+		{"llama 3 8b f16", llama3_8b, 4096, "f16", "f16", 1, 512 * mib},
+		{"llama 3 8b trained context", llama3_8b, 0, "f16", "f16", 1, 1024 * mib},
+		{"llama 3 8b slots share the context", llama3_8b, 4096, "f16", "f16", 4, 512 * mib},
+		{"llama 3 8b q8_0", llama3_8b, 4096, "q8_0", "q8_0", 1, 272 * mib},
+		{"llama 3 8b q4_0", llama3_8b, 4096, "q4_0", "q4_0", 1, 144 * mib},
+		{"llama 3 8b q8_0 keys", llama3_8b, 4096, "q8_0", "f16", 1, 392 * mib},
+		{"llama 3 8b f32", llama3_8b, 4096, "f32", "f32", 1, 1024 * mib},
+		{"unknown type counts as f16", llama3_8b, 4096, "q3_k", "", 1, 512 * mib},
+		{"qwen 2.5 7b", qwen25_7b, 32768, "f16", "f16", 1, 1792 * mib},
+		{"gemma 2 9b head size from key_length", gemma2_9b, 8192, "f16", "f16", 1, 2688 * mib},
+		{"llama 3 70b", llama3_70b, 8192, "f16", "f16", 1, 2560 * mib},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MemoryOptions{ContextSize: tt.ctx, UBatchSize: 512, BatchSize: 2048, Parallel: tt.parallel, CacheTypeK: tt.k, CacheTypeV: tt.v}
+			estimate := EstimateMemory(tt.shape.model(0), opts)
+			if estimate.KVCache != tt.want {
+				t.Errorf("KV cache = %d MiB, want %d MiB", estimate.KVCache/mib, tt.want/mib)
+			}
+			if tt.ctx == 0 && estimate.ContextSize != int(tt.shape.context) {
+				t.Errorf("context = %d, want the trained %d", estimate.ContextSize, tt.shape.context)
+			}
+		})
+	}
+}
+
+func TestMemoryOptionsFromPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		want    MemoryOptions
+	}{
+		{"llama-server defaults", "", MemoryOptions{ContextSize: 4096, BatchSize: 2048, UBatchSize: 512, Parallel: 1, CacheTypeK: "f16", CacheTypeV: "f16"}},
+		{"short flags", "-c 8192 -ngl 20 -ctk Q8_0 -ctv q4_0 -fa on -np 2",
+			MemoryOptions{ContextSize: 8192, BatchSize: 2048, UBatchSize: 512, Parallel: 2, GPULayers: 20, CacheTypeK: "q8_0", CacheTypeV: "q4_0", FlashAttention: true}},
+		{"all layers", "n_ctx=0\nn_gpu_layers=all\nflash_attn=off",
+			MemoryOptions{ContextSize: 0, BatchSize: 2048, UBatchSize: 512, Parallel: 1, GPULayers: -1, CacheTypeK: "f16", CacheTypeV: "f16"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MemoryOptionsFromPreset(&Preset{Options: ParsePresetOptions(tt.options)})
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateGPUSplit(t *testing.T) {
+	// 4Gi f16 elements make 8 GiB of weights, roughly a Q8_0 8B model
+	model := llama3_8b.model(4 << 30)
+
+	tests := []struct {
+		name      string
+		gpuLayers int
+		fraction  float64
+	}{
+		{"cpu only", 0, 0},
+		{"half the layers", 16, 16.0 / 33},
+		{"every layer but the output", 32, 32.0 / 33},
+		{"every layer", 33, 1},
+		{"more than the model has", 999, 1},
+		{"all", -1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := EstimateMemory(model, MemoryOptions{ContextSize: 4096, BatchSize: 2048, UBatchSize: 512, Parallel: 1, GPULayers: tt.gpuLayers})
+			if estimate.Weights != 8<<30 {
+				t.Fatalf("weights = %d, want 8 GiB", estimate.Weights)
+			}
+			if estimate.GPUFraction != tt.fraction {
+				t.Errorf("GPU fraction = %v, want %v", estimate.GPUFraction, tt.fraction)
+			}
+
+			// Offloaded layers take their share of weights and KV cache to
+			// the GPU; the compute buffer goes wherever the output layer is
+			total := estimate.Weights + estimate.KVCache
+			switch tt.fraction {
+			case 0:
+				if estimate.GPU() != 0 || estimate.Host() != total+estimate.Compute {
+					t.Errorf("host %d, gpu %d, want everything on the host", estimate.Host(), estimate.GPU())
+				}
+			case 1:
+				if estimate.Host() != 0 || estimate.GPU() != total+estimate.Compute {
+					t.Errorf("host %d, gpu %d, want everything on the GPU", estimate.Host(), estimate.GPU())
+				}
+			default:
+				if gpu := int64(float64(total) * tt.fraction); estimate.GPU() != gpu {
+					t.Errorf("gpu = %d, want %d", estimate.GPU(), gpu)
+				}
+				if diff := estimate.Host() + estimate.GPU() - total - estimate.Compute; diff < -1 || diff > 1 {
+					t.Errorf("host %d + gpu %d don't add up to %d", estimate.Host(), estimate.GPU(), total+estimate.Compute)
+				}
+			}
+		})
+	}
+
+	// Llama 3 8B at 512-token micro-batches: logits, feed-forward and hidden
+	// state activations, plus the attention scores without flash attention
+	opts := MemoryOptions{ContextSize: 4096, BatchSize: 2048, UBatchSize: 512, Parallel: 1}
+	if compute := EstimateMemory(model, opts).Compute; compute != 512*(128256+2*14336+4*4096)*4+512*4096*32*4 {
+		t.Errorf("compute buffer = %d", compute)
+	}
+	opts.FlashAttention = true
+	if compute := EstimateMemory(model, opts).Compute; compute != 512*(128256+2*14336+4*4096)*4 {
+		t.Errorf("compute buffer with flash attention = %d", compute)
+	}
+}
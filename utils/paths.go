@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// appName is the directory name used under each XDG base directory
+const appName = "llamarunner"
+
+// Dirs holds the home directory and the XDG base directories derived from it
+type Dirs struct {
+	Home string
+
+	// Config holds settings and presets
+	Config string
+
+	// State holds runtime state such as PIDs and logs
+	State string
+
+	// Cache holds caches such as indexes and downloads
+	Cache string
+
+	// Data holds user data such as models
+	Data string
+}
+
+// NewDirs resolves the XDG directories for home, reading the XDG variables
+// through getenv
+func NewDirs(home string, getenv func(string) string) Dirs {
+	// xdgDir falls back to a path under home when the variable is unset or
+	// not absolute, as the specification requires
+	xdgDir := func(env, fallback string) string {
+		if dir := getenv(env); filepath.IsAbs(dir) {
+			return filepath.Join(dir, appName)
+		}
+		return filepath.Join(home, fallback, appName)
+	}
+
+	return Dirs{
+		Home:   home,
+		Config: xdgDir("XDG_CONFIG_HOME", ".config"),
+		State:  xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state")),
+		Cache:  xdgDir("XDG_CACHE_HOME", ".cache"),
+		Data:   xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")),
+	}
+}
+
+// Models returns the default directory for model files
+func (d Dirs) Models() string {
+	return filepath.Join(d.Data, "models")
+}
+
+// Datasets returns the directory of named calibration datasets
+func (d Dirs) Datasets() string {
+	return filepath.Join(d.Data, "datasets")
+}
+
+// Venv returns the Python virtualenv used for llama.cpp's conversion scripts
+func (d Dirs) Venv() string {
+	return filepath.Join(d.Data, "venv")
+}
+
+// Wheels returns the local cache of Python wheels installed into the venv
+func (d Dirs) Wheels() string {
+	return filepath.Join(d.Cache, "wheels")
+}
+
+// Store returns the content-addressed model store
+func (d Dirs) Store() string {
+	return filepath.Join(d.Data, "store")
+}
+
+// BuildProfiles returns the directory of named build profiles
+func (d Dirs) BuildProfiles() string {
+	return filepath.Join(d.Config, "build-profiles")
+}
+
+// Legacy returns the pre-XDG ~/.llama-presets directory
+func (d Dirs) Legacy() string {
+	return filepath.Join(d.Home, ".llama-presets")
+}
+
+// ExpandPath expands a leading ~, $HOME and other environment variables in
+// path, using d.Home for the home directory
+func (d Dirs) ExpandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(d.Home, path[1:])
+	}
+	return os.Expand(path, func(name string) string {
+		if name == "HOME" {
+			return d.Home
+		}
+		return os.Getenv(name)
+	})
+}
+
+// MigrateLegacyDir moves ~/.llama-presets into the XDG directories once.
+// Settings and presets go to the config dir and models to the data dir, and
+// ~/.llama-presets is left as a symlink to the config dir for old scripts.
+func MigrateLegacyDir(dirs Dirs) error {
+	legacyDir := dirs.Legacy()
+	configDir := dirs.Config
+
+	info, err := os.Lstat(legacyDir)
+	if err != nil || !info.IsDir() {
+		// Nothing to migrate, or already replaced by a symlink
+		return nil
+	}
+
+	// An existing XDG settings file means the user already moved over
+	if FileExists(filepath.Join(configDir, "settings.toml")) {
+		return nil
+	}
+
+	fmt.Printf("Migrating %s to XDG directories...\n", legacyDir)
+
+	err = os.MkdirAll(configDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(legacyDir)
+	if err != nil {
+		return err
+	}
+
+	legacyModels := filepath.Join(legacyDir, "models")
+	for _, entry := range entries {
+		src := filepath.Join(legacyDir, entry.Name())
+		dst := filepath.Join(configDir, entry.Name())
+		if src == legacyModels {
+			dst = dirs.Models()
+		}
+
+		// Never clobber something the user already created in the new place
+		if FileExists(dst) {
+			return fmt.Errorf("cannot migrate %s: %s already exists", src, dst)
+		}
+
+		err = movePath(src, dst)
+		if err != nil {
+			return fmt.Errorf("error moving %s to %s: %v", src, dst, err)
+		}
+		fmt.Printf("  %s -> %s\n", src, dst)
+	}
+
+	err = rewriteMigratedSettings(dirs, filepath.Join(configDir, "settings.toml"))
+	if err != nil {
+		return err
+	}
+
+	err = rewriteMigratedPresets(dirs)
+	if err != nil {
+		return err
+	}
+
+	// Leave a compatibility symlink behind
+	err = os.Remove(legacyDir)
+	if err == nil {
+		err = os.Symlink(configDir, legacyDir)
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not replace %s with a symlink: %v\n", legacyDir, err)
+	}
+
+	fmt.Println("Migration complete.")
+	return nil
+}
+
+// migratedPath maps a path inside ~/.llama-presets to its new location
+func migratedPath(dirs Dirs, path string) string {
+	legacyDir := dirs.Legacy()
+	expanded := dirs.ExpandPath(path)
+
+	// Older defaults pointed model_path at settings.toml itself
+	if expanded == filepath.Join(legacyDir, "settings.toml") {
+		return dirs.Models()
+	}
+
+	legacyModels := filepath.Join(legacyDir, "models")
+	if expanded == legacyModels || strings.HasPrefix(expanded, legacyModels+string(filepath.Separator)) {
+		return dirs.Models() + strings.TrimPrefix(expanded, legacyModels)
+	}
+
+	if expanded == legacyDir || strings.HasPrefix(expanded, legacyDir+string(filepath.Separator)) {
+		return dirs.Config + strings.TrimPrefix(expanded, legacyDir)
+	}
+
+	return path
+}
+
+// rewriteMigratedSettings points path settings at their new locations
+func rewriteMigratedSettings(dirs Dirs, path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	for key := range pathSettings {
+		if value, ok := tree.Get(key).(string); ok {
+			tree.Set(key, migratedPath(dirs, value))
+		}
+	}
+
+	data, err = tree.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, data, 0644)
+}
+
+// rewriteMigratedPresets updates model paths inside preset files
+func rewriteMigratedPresets(dirs Dirs) error {
+	presets, err := filepath.Glob(filepath.Join(dirs.Config, "*.cfg"))
+	if err != nil {
+		return err
+	}
+
+	legacyModels := filepath.Join(dirs.Legacy(), "models")
+	for _, preset := range presets {
+		data, err := os.ReadFile(preset)
+		if err != nil {
+			return err
+		}
+
+		content := string(data)
+		if !strings.Contains(content, legacyModels) {
+			continue
+		}
+
+		content = strings.ReplaceAll(content, legacyModels, dirs.Models())
+		err = WriteFileAtomic(preset, []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// movePath renames src to dst, copying across filesystems when needed
+func movePath(src, dst string) error {
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// Rename fails across devices, e.g. when $XDG_DATA_HOME is another disk
+	err = copyPath(src, dst)
+	if err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// copyPath recursively copies files and directories from src to dst
+func copyPath(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return CopyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// presetAliases maps short llama-server flags to the canonical key used by
+// Preset.Get
+var presetAliases = map[string]string{
+	"m":          "model",
+	"c":          "ctx_size",
+	"t":          "threads",
+	"n":          "n_predict",
+	"b":          "batch_size",
+	"ub":         "ubatch_size",
+	"ngl":        "n_gpu_layers",
+	"gpu_layers": "n_gpu_layers",
+	"np":         "parallel",
+	"ctk":        "cache_type_k",
+	"ctv":        "cache_type_v",
+	"fa":         "flash_attn",
+	"mm":         "mmproj",
+	"n_ctx":      "ctx_size",
+	"n_batch":    "batch_size",
+	"n_ubatch":   "ubatch_size",
+	"n_parallel": "parallel",
+	"n_threads":  "threads",
+}
+
+// PresetOption is one option from a preset file
+type PresetOption struct {
+	// Key is normalized: no dashes, lower case, "_" separators, aliases
+	// resolved
+	Key   string
+	Value string
+}
+
+// Preset is a parsed preset file
+type Preset struct {
+	Name    string
+	Path    string
+	Options []PresetOption
+}
+
+// PresetPath returns the path of the preset file with the given name
+func PresetPath(ctx *Context, name string) string {
+	return filepath.Join(FindConfigDir(ctx), name+".cfg")
+}
+
+// LoadPreset reads and parses the named preset
+func LoadPreset(ctx *Context, name string) (*Preset, error) {
+	path := PresetPath(ctx, name)
+	if !FileExists(path) {
+		return nil, fmt.Errorf("preset config file not found: %s", path)
+	}
+
+	return ParsePresetFile(path)
+}
+
+// ListPresets returns the names of all presets in the config directory
+func ListPresets(ctx *Context) ([]string, error) {
+	files, err := os.ReadDir(FindConfigDir(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".cfg") {
+			names = append(names, strings.TrimSuffix(file.Name(), ".cfg"))
+		}
+	}
+	return names, nil
+}
+
+// ParsePresetFile parses a preset file at path
+func ParsePresetFile(path string) (*Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Preset{
+		Name:    strings.TrimSuffix(filepath.Base(path), ".cfg"),
+		Path:    path,
+		Options: ParsePresetOptions(string(data)),
+	}, nil
+}
+
+// ParsePresetOptions parses preset content written either as key=value lines
+// or as llama-server flags ("-c 4096", "--ctx-size=4096")
+func ParsePresetOptions(content string) []PresetOption {
+	var tokens []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Drop trailing comments such as "ngl=35  # layers on GPU"
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+
+	var options []PresetOption
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if !strings.HasPrefix(token, "-") || isNumber(token) {
+			key, value, found := strings.Cut(token, "=")
+
+			// Tolerate a space after the "=", as in "threads= -1"
+			if found && value == "" && i+1 < len(tokens) && !strings.Contains(tokens[i+1], "=") {
+				value = tokens[i+1]
+				i++
+			}
+
+			if found {
+				options = append(options, PresetOption{Key: normalizePresetKey(key), Value: strings.TrimSpace(value)})
+			}
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimLeft(token, "-"), "=")
+		if !found {
+			// The value is the next token unless that is another flag
+			if i+1 < len(tokens) && (!strings.HasPrefix(tokens[i+1], "-") || isNumber(tokens[i+1])) {
+				value = tokens[i+1]
+				i++
+			} else {
+				value = "true"
+			}
+		}
+
+		options = append(options, PresetOption{Key: normalizePresetKey(key), Value: value})
+	}
+
+	return options
+}
+
+// Get returns the last value set for key, which may be a short flag or an
+// alias such as "ngl"
+func (p *Preset) Get(key string) (string, bool) {
+	key = normalizePresetKey(key)
+
+	value, found := "", false
+	for _, option := range p.Options {
+		if option.Key == key {
+			value, found = option.Value, true
+		}
+	}
+	return value, found
+}
+
+// GetInt returns an integer option, or def when unset or invalid
+func (p *Preset) GetInt(key string, def int) int {
+	value, ok := p.Get(key)
+	if !ok {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// ModelPath returns the preset's model path with ~ and variables expanded.
+// Store aliases resolve to the alias's model file and other relative paths
+// are resolved against the first model directory.
+func (p *Preset) ModelPath(ctx *Context) string {
+	model, ok := p.Get("model")
+	if !ok || model == "" {
+		return ""
+	}
+
+	model = ctx.Dirs.ExpandPath(model)
+	if path, ok := ResolveAlias(ctx, model); ok {
+		return path
+	}
+	if !filepath.IsAbs(model) {
+		if dirs := ModelDirs(ctx); len(dirs) > 0 {
+			model = filepath.Join(dirs[0], model)
+		}
+	}
+	return filepath.Clean(model)
+}
+
+// SetModel rewrites the preset file to use model, keeping the rest of the
+// file, including comments and option style, unchanged
+func (p *Preset) SetModel(model string) error {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+
+	content, replaced := replaceModel(string(data), model)
+	if !replaced {
+		content = "model=" + model + "\n" + content
+	}
+
+	err = WriteFileAtomic(p.Path, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	p.Options = ParsePresetOptions(content)
+	return nil
+}
+
+// replaceModel replaces the model value in preset content, reporting
+// whether a model option was found
+func replaceModel(content, model string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	replaced := false
+	for i, line := range lines {
+		for _, option := range ParsePresetOptions(line) {
+			if option.Key != "model" || option.Value == "" {
+				continue
+			}
+
+			// The value appears verbatim, as in "model=x" or "-m x"
+			start := strings.Index(line, option.Value)
+			lines[i] = line[:start] + model + line[start+len(option.Value):]
+			replaced = true
+		}
+	}
+	return strings.Join(lines, "\n"), replaced
+}
+
+// ModelDirs returns the configured model directories. model_path may list
+// several directories separated by ":".
+func ModelDirs(ctx *Context) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(ctx.Settings.ModelPath) {
+		if dir != "" {
+			dirs = append(dirs, ctx.Dirs.ExpandPath(dir))
+		}
+	}
+	return dirs
+}
+
+// normalizePresetKey strips dashes and resolves aliases
+func normalizePresetKey(key string) string {
+	key = strings.ToLower(strings.TrimLeft(strings.TrimSpace(key), "-"))
+	if alias, ok := presetAliases[key]; ok {
+		return alias
+	}
+
+	key = strings.ReplaceAll(key, "-", "_")
+	if alias, ok := presetAliases[key]; ok {
+		return alias
+	}
+	return key
+}
+
+// isNumber reports whether s parses as a number, so "-1" is a value, not a flag
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
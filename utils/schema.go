@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml"
+)
+
+// CurrentSchemaVersion is the settings schema this build reads and writes.
+// Files without a schema_version key are treated as version 1.
+const CurrentSchemaVersion = 4
+
+// settingsMigration upgrades a settings tree from version to version+1
+type settingsMigration struct {
+	version     int
+	description string
+	migrate     func(dirs Dirs, tree *toml.Tree) error
+}
+
+// settingsMigrations lists every schema upgrade in order
+var settingsMigrations = []settingsMigration{
+	{
+		version:     1,
+		description: "point model_path at the models directory instead of settings.toml",
+		migrate:     migrateModelPathV1,
+	},
+	{
+		version:     2,
+		description: "introduce hub_url for model downloads",
+		migrate:     migrateHubURLV2,
+	},
+	{
+		version:     3,
+		description: "introduce build_profile for llama.cpp builds",
+		migrate:     migrateBuildProfileV3,
+	},
+}
+
+// schemaVersion returns the schema version recorded in a settings tree
+func schemaVersion(tree *toml.Tree) (int, error) {
+	if !tree.Has("schema_version") {
+		return 1, nil
+	}
+
+	version, ok := tree.Get("schema_version").(int64)
+	if !ok || version < 1 {
+		return 0, fmt.Errorf("invalid schema_version %v", tree.Get("schema_version"))
+	}
+
+	return int(version), nil
+}
+
+// checkSchemaVersion rejects settings written by a newer llamarunner
+func checkSchemaVersion(tree *toml.Tree) error {
+	version, err := schemaVersion(tree)
+	if err != nil {
+		return err
+	}
+
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("settings use schema version %d but this llamarunner only supports up to version %d; please run 'llamarunner update'",
+			version, CurrentSchemaVersion)
+	}
+
+	return nil
+}
+
+// migrateSettingsTree applies all pending migrations to tree and reports the
+// version it started from
+func migrateSettingsTree(dirs Dirs, tree *toml.Tree) (int, error) {
+	from, err := schemaVersion(tree)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkSchemaVersion(tree)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range settingsMigrations {
+		if migration.version < from {
+			continue
+		}
+
+		err = migration.migrate(dirs, tree)
+		if err != nil {
+			return 0, fmt.Errorf("migration from schema version %d failed: %v", migration.version, err)
+		}
+		tree.Set("schema_version", int64(migration.version+1))
+	}
+
+	return from, nil
+}
+
+// migrateSettingsFile upgrades a settings file on disk, keeping a backup of
+// the original next to it
+func migrateSettingsFile(dirs Dirs, path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	from, err := migrateSettingsTree(dirs, tree)
+	if err != nil {
+		return fmt.Errorf("error migrating %s: %v", path, err)
+	}
+	if from == CurrentSchemaVersion {
+		return nil
+	}
+
+	// Leave a broken file as the user wrote it, for them to fix
+	err = checkSettingKeys(tree)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.bak", path, from)
+	err = WriteFileAtomic(backupPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error backing up %s: %v", path, err)
+	}
+
+	migrated, err := tree.Marshal()
+	if err != nil {
+		return err
+	}
+
+	err = WriteFileAtomic(path, migrated, 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Upgraded %s from schema version %d to %d (backup: %s)\n",
+		path, from, CurrentSchemaVersion, backupPath)
+	for _, migration := range settingsMigrations {
+		if migration.version >= from {
+			fmt.Printf("  - %s\n", migration.description)
+		}
+	}
+
+	return nil
+}
+
+// migrateModelPathV1 fixes model_path values written by early versions, which
+// pointed it at settings.toml itself
+func migrateModelPathV1(dirs Dirs, tree *toml.Tree) error {
+	modelPath, ok := tree.Get("model_path").(string)
+	if !ok {
+		return nil
+	}
+
+	if filepath.Base(dirs.ExpandPath(modelPath)) == "settings.toml" {
+		tree.Set("model_path", dirs.Models())
+	}
+
+	return nil
+}
+
+// migrateHubURLV2 needs no changes: hub_url falls back to DefaultHubURL when
+// unset. The version bump makes older llamarunner builds reject files that
+// may contain the new key with a clear error instead of "unknown setting".
+func migrateHubURLV2(dirs Dirs, tree *toml.Tree) error {
+	return nil
+}
+
+// migrateBuildProfileV3 needs no changes: an empty build_profile builds with
+// the defaults. Like migrateHubURLV2 it only bumps the version.
+func migrateBuildProfileV3(dirs Dirs, tree *toml.Tree) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// aliasNamePattern allows names such as "qwen2.5-7b:q4_k_m"; slashes are
+// excluded since each alias is a directory
+var aliasNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:+-]*$`)
+
+// ValidAliasName reports whether name can be used as a store alias
+func ValidAliasName(name string) bool {
+	return aliasNamePattern.MatchString(name)
+}
+
+// StoreBlobsDir returns the directory holding model files named by sha256
+func StoreBlobsDir(ctx *Context) string {
+	return filepath.Join(ctx.Dirs.Store(), "blobs")
+}
+
+// AliasDir returns the directory of an alias. It holds symlinks to the
+// alias's blobs under the original file names, so split models keep their
+// shard names.
+func AliasDir(ctx *Context, name string) string {
+	return filepath.Join(ctx.Dirs.Store(), "aliases", name)
+}
+
+// AliasFiles returns the model files of an alias, first shard first
+func AliasFiles(ctx *Context, name string) ([]string, error) {
+	entries, err := os.ReadDir(AliasDir(ctx, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if strings.HasSuffix(strings.ToLower(entry.Name()), ".gguf") {
+			files = append(files, filepath.Join(AliasDir(ctx, name), entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ResolveAlias returns the model file for an alias, which is the first shard
+// of a split model
+func ResolveAlias(ctx *Context, name string) (string, bool) {
+	if !ValidAliasName(name) {
+		return "", false
+	}
+
+	files, err := AliasFiles(ctx, name)
+	if err != nil || len(files) == 0 {
+		return "", false
+	}
+	return files[0], true
+}
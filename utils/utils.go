@@ -3,7 +3,6 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -14,87 +13,64 @@ import (
 	"github.com/pelletier/go-toml"
 )
 
-var homeFolder string = os.Getenv("HOME")
-
 // Settings represents the application configuration
 type Settings struct {
-	LlamaCppPath string `toml:"llama_cpp_path"`
-	ModelPath    string `toml:"model_path"`
-	ConfigPath   string `toml:"config_path"`
-	Host         string `toml:"host"`
-	Port         string `toml:"port"`
-	ForceCPU     bool   `toml:"force_cpu"`
-	Version      string `toml:"version"`
+	LlamaCppPath  string `toml:"llama_cpp_path"`
+	ModelPath     string `toml:"model_path"`
+	ConfigPath    string `toml:"config_path"`
+	Host          string `toml:"host"`
+	Port          string `toml:"port"`
+	ForceCPU      bool   `toml:"force_cpu"`
+	Version       string `toml:"version"`
+	HubURL        string `toml:"hub_url"`
+	BuildProfile  string `toml:"build_profile"`
+	SchemaVersion int    `toml:"schema_version"` // see CurrentSchemaVersion
 }
 
-const (
-	SETTINGS_FILE = "$HOME/.llama-presets/settings.toml"
-)
+// DefaultHubURL is the model hub used by "model pull" unless hub_url is set
+const DefaultHubURL = "https://huggingface.co"
 
-// GetDefaultConfigDir returns the default config directory path
-func GetDefaultConfigDir() string {
-	return filepath.Join(homeFolder, ".llama-presets")
-}
-
-// LoadSettings loads settings from file or creates default if not found
-func LoadSettings() (*Settings, error) {
-	// Try user settings first
-	userSettingsFile := getUserSettingsFile()
-	settings, err := loadSettingsFromFile(userSettingsFile)
-	if err == nil && settings != nil {
-		return settings, nil
+// getUserSettingsFile returns the path to the user's settings file in the XDG
+// config dir, falling back to ~/.llama-presets if it was never migrated
+func getUserSettingsFile(dirs Dirs) string {
+	xdgFile := filepath.Join(dirs.Config, "settings.toml")
+	if FileExists(xdgFile) {
+		return xdgFile
 	}
 
-	// Try system settings
-	settings, err = loadSettingsFromFile(SETTINGS_FILE)
-	if err == nil && settings != nil {
-		return settings, nil
+	legacyFile := filepath.Join(dirs.Legacy(), "settings.toml")
+	if FileExists(legacyFile) {
+		return legacyFile
 	}
 
-	// Create default settings
-	return createDefaultSettings()
+	return xdgFile
 }
 
-// getUserSettingsFile returns the path to the user's settings file
-func getUserSettingsFile() string {
-	homeDir := os.Getenv("HOME")
-	return filepath.Join(homeDir, ".llama-presets", "settings.toml")
-}
-
-// loadSettingsFromFile loads settings from a specific file path
-func loadSettingsFromFile(path string) (*Settings, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var settings Settings
-	err = toml.Unmarshal(data, &settings)
-	if err != nil {
-		return nil, err
+// defaultSettings returns the built-in settings used as the lowest layer
+func defaultSettings(dirs Dirs) *Settings {
+	return &Settings{
+		LlamaCppPath:  "$HOME/llama.cpp",
+		ModelPath:     dirs.Models(),
+		ConfigPath:    filepath.Dir(getUserSettingsFile(dirs)),
+		Host:          "localhost",
+		Port:          "8080",
+		ForceCPU:      false,
+		Version:       "dev", // Default version for development builds
+		HubURL:        DefaultHubURL,
+		SchemaVersion: CurrentSchemaVersion,
 	}
-
-	return &settings, nil
 }
 
 // createDefaultSettings creates and saves default settings
-func createDefaultSettings() (*Settings, error) {
-	settings := &Settings{
-		LlamaCppPath: "$HOME/llama.cpp",
-		ModelPath:    getUserSettingsFile(),
-		ConfigPath:   filepath.Dir(getUserSettingsFile()),
-		Host:         "localhost",
-		Port:         "8080",
-		ForceCPU:     false,
-		Version:      "dev", // Default version for development builds
-	}
+func createDefaultSettings(dirs Dirs) (*Settings, error) {
+	settings := defaultSettings(dirs)
 
 	// Create settings directory if needed
-	userDir := filepath.Dir(getUserSettingsFile())
-	os.MkdirAll(userDir, 0755)
+	userFile := getUserSettingsFile(dirs)
+	os.MkdirAll(filepath.Dir(userFile), 0755)
 
 	// Save default settings
-	err := SaveSettings(settings)
+	err := writeSettingsFile(userFile, settings)
 	if err != nil {
 		return settings, err
 	}
@@ -102,39 +78,25 @@ func createDefaultSettings() (*Settings, error) {
 	return settings, nil
 }
 
-// SaveSettings saves settings to the user's config directory
-func SaveSettings(settings *Settings) error {
-	// Save to user settings
+// writeSettingsFile writes every field of settings to path
+func writeSettingsFile(path string, settings *Settings) error {
 	data, err := toml.Marshal(settings)
 	if err != nil {
 		return err
 	}
 
-	// Use utils function to get the config directory
-	configDir := GetDefaultConfigDir()
-	userFile := filepath.Join(configDir, "settings.toml")
-
-	err = os.WriteFile(userFile, data, 0644)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return os.WriteFile(path, data, 0644)
 }
 
 // SetDefaultSettings sets and saves default settings
-func SetDefaultSettings() {
-	settings := &Settings{
-		LlamaCppPath: "$HOME/llama.cpp",
-		ModelPath:    filepath.Join(filepath.Dir(getUserSettingsFile()), "models"),
-		ConfigPath:   filepath.Dir(getUserSettingsFile()),
-		Host:         "localhost",
-		Port:         "8080",
-		ForceCPU:     false,
-		Version:      "dev", // Default version for development builds
+func SetDefaultSettings(ctx *Context) {
+	settings := defaultSettings(ctx.Dirs)
+
+	err := writeSettingsFile(getUserSettingsFile(ctx.Dirs), settings)
+	if err == nil {
+		err = ctx.Reload()
 	}
 
-	err := SaveSettings(settings)
 	if err != nil {
 		fmt.Printf("Error setting default settings: %v\n", err)
 	} else {
@@ -142,113 +104,47 @@ func SetDefaultSettings() {
 	}
 }
 
-// EditSettingsFile displays current settings information
-func EditSettingsFile() {
-	settings, err := LoadSettings()
-	if err != nil {
-		fmt.Printf("Error loading settings: %v\n", err)
-		return
-	}
-
-	// Get the actual expanded path
-	actualPath := GetDefaultConfigDir()
-	settingsFile := filepath.Join(actualPath, "settings.toml")
-
-	fmt.Printf("Editing settings file: %s\n", settingsFile)
-	fmt.Println("Current settings:")
-	fmt.Printf("LlamaCppPath: %s\n", settings.LlamaCppPath)
-	fmt.Printf("ModelPath: %s\n", settings.ModelPath)
-	fmt.Printf("ConfigPath: %s\n", settings.ConfigPath)
-	fmt.Printf("Host: %s\n", settings.Host)
-	fmt.Printf("Port: %s\n", settings.Port)
-	fmt.Printf("ForceCPU: %t\n", settings.ForceCPU)
-	fmt.Printf("Version: %s\n", settings.Version)
-
-	// You can add logic here to actually open nano or other editor
-	// For now, just show that it would edit the file
-	fmt.Println("Note: This would normally open your editor to modify the file.")
-	fmt.Println("You can manually edit: " + settingsFile)
-}
-
-func FindLlamaCppDir() string {
-	settings, err := LoadSettings()
-	if err != nil || settings.LlamaCppPath == "" {
+// FindLlamaCppDir returns the llama.cpp checkout configured in settings
+func FindLlamaCppDir(ctx *Context) string {
+	if ctx.Settings.LlamaCppPath == "" {
 		fmt.Println("Error: no installation directory specified in settings")
 	} else {
-		return settings.LlamaCppPath
+		return ctx.Settings.LlamaCppPath
 	}
 
 	// Try to find llama.cpp default installation
-	llamaDir := filepath.Join(homeFolder, "llama.cpp")
+	llamaDir := filepath.Join(ctx.Dirs.Home, "llama.cpp")
 	return llamaDir // fallback
 }
 
-func FindConfigDir() string {
-	// Try user config directory first
-	settings, err := LoadSettings()
-	if err != nil || settings.ConfigPath == "" {
-		fmt.Println("Error: no config directory specified in settings")
-	} else {
-		return settings.ConfigPath
-	}
-
-	configDir := filepath.Join(homeFolder, "llama.cpp")
-
-	// If directory exists, use it
-	if _, err := os.Stat(configDir); err == nil {
-		return configDir
-	}
-
-	// Ask user or create default
-	fmt.Printf("Config directory not found: %s\n", configDir)
-	fmt.Print("Enter config directory path (or press Enter to create it): ")
-
-	var input string
-	fmt.Scanln(&input)
+// LlamaBinaryPath returns where a llama.cpp tool such as llama-server is built
+func LlamaBinaryPath(ctx *Context, name string) string {
+	return filepath.Join(ctx.Dirs.ExpandPath(FindLlamaCppDir(ctx)), "build", "bin", name)
+}
 
-	if input != "" {
-		return input
+// FindConfigDir returns the preset directory configured in settings, falling
+// back to the XDG config dir
+func FindConfigDir(ctx *Context) string {
+	configDir := ctx.Settings.ConfigPath
+	if configDir == "" {
+		fmt.Println("Error: no config directory specified in settings")
+		configDir = ctx.Dirs.Config
 	}
 
-	// Create the default directory
+	// Create the directory on first use
 	os.MkdirAll(configDir, 0755)
 	return configDir
 }
 
-func LoadConfig() (string, string, error) {
-	// Find the config directory
-	configDir := FindConfigDir()
-
-	// Construct the full path to settings.toml
-	configPath := filepath.Join(configDir, "settings.toml")
-
-	// Check if the file exists
-	if !FileExists(configPath) {
-		return "", "", fmt.Errorf("settings.toml not found: %s", configPath)
-	}
-
-	// Read and parse the TOML file
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Parse TOML
-	tomlData, err := toml.LoadBytes(data)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Extract host and port values with defaults
-	host := tomlData.Get("host").(string)
-	port := tomlData.Get("port").(string)
-
-	return host, port, nil
+// LoadConfig returns the effective server host and port
+func LoadConfig(ctx *Context) (string, string, error) {
+	return ctx.Settings.Host, ctx.Settings.Port, nil
 }
 
-func LoadPresetConfig(presetName string) (string, error) {
+// LoadPresetConfig builds the llama-server command line for a preset
+func LoadPresetConfig(ctx *Context, presetName string) (string, error) {
 	// Find the config directory
-	configDir := FindConfigDir()
+	configDir := FindConfigDir(ctx)
 
 	// Construct the full path to the preset config file
 	configPath := filepath.Join(configDir, presetName+".cfg")
@@ -264,19 +160,26 @@ func LoadPresetConfig(presetName string) (string, error) {
 		return "", err
 	}
 
-	// Parse host and port from settings.toml
-	host, port, err := LoadConfig()
+	// Host and port come from the effective settings
+	host, port, err := LoadConfig(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to load config: %v", err)
 	}
 
-	// Get the llama.cpp directory
-	llamaDir := FindLlamaCppDir()
-	binaryPath := filepath.Join(llamaDir, "build", "bin", "llama-server")
+	binaryPath := LlamaBinaryPath(ctx, "llama-server")
 
 	// Read preset content and build enhanced command
 	presetContent := strings.TrimSpace(string(data))
 
+	// llama-server only understands paths, so replace a store alias with
+	// the file it stands for
+	preset := &Preset{Options: ParsePresetOptions(presetContent)}
+	if model, ok := preset.Get("model"); ok {
+		if path, ok := ResolveAlias(ctx, ctx.Dirs.ExpandPath(model)); ok {
+			presetContent, _ = replaceModel(presetContent, path)
+		}
+	}
+
 	// Build the enhanced command string
 	// Format: llama-server --host <host> --port <port> [preset arguments]
 	var builder strings.Builder
@@ -291,19 +194,6 @@ func LoadPresetConfig(presetName string) (string, error) {
 	return builder.String(), nil
 }
 
-// HasCUDA checks if CUDA is available on the system
-func HasCUDA() (bool, error) {
-	// Check if nvcc is available in PATH
-	if !isCommandAvailable("nvcc") {
-		return false, nil
-	}
-
-	// Additional CUDA checks could be added here if needed
-	// For now, just checking for nvcc availability
-
-	return true, nil
-}
-
 // isCommandAvailable checks if a command is available in the system PATH
 func isCommandAvailable(cmd string) bool {
 	_, err := exec.LookPath(cmd)
@@ -326,28 +216,6 @@ type GitHubRelease struct {
 	} `json:"assets"`
 }
 
-// DownloadFile downloads a file from a URL to a local path
-func DownloadFile(url, filepath string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	out, err := os.Create(filepath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
 // GetLatestGitHubRelease fetches the latest release from GitHub
 func GetLatestGitHubRelease(owner, repo string) (*GitHubRelease, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", owner, repo)